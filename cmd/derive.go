@@ -0,0 +1,98 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/icon-project/goloop/common"
+	"github.com/icon-project/rosetta-icon/icon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	deriveCmd = &cobra.Command{
+		Use:   "derive",
+		Short: "Derive the keys of a mnemonic along m/44'/74'/account'/0/index",
+		Long: `Derive the secp256k1 keys of a BIP39 mnemonic along m/44'/74'/account'/0/index
+and print their addresses and the public keys for /construction/derive.
+The mnemonic is read from stdin. It never connects to the network.`,
+		RunE: runDeriveCmd,
+	}
+
+	deriveAccount        uint32
+	deriveIndex          uint32
+	deriveCount          uint32
+	derivePassphraseFile string
+)
+
+// derivedKey is a key derived from a mnemonic.
+type derivedKey struct {
+	Path      string           `json:"path"`
+	Address   string           `json:"address"`
+	PublicKey *types.PublicKey `json:"public_key"`
+}
+
+func init() {
+	deriveCmd.Flags().Uint32Var(&deriveAccount, "account", 0, "account of the keys")
+	deriveCmd.Flags().Uint32Var(&deriveIndex, "index", 0, "index of the first key")
+	deriveCmd.Flags().Uint32Var(&deriveCount, "count", 1, "number of keys")
+	deriveCmd.Flags().StringVar(&derivePassphraseFile, "passphrase-file", "", "file of the BIP39 passphrase")
+}
+
+func runDeriveCmd(cmd *cobra.Command, args []string) error {
+	mnemonic, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(mnemonic) == 0 {
+		return fmt.Errorf("%w: unable to read mnemonic", err)
+	}
+	var passphrase []byte
+	if len(derivePassphraseFile) > 0 {
+		if passphrase, err = ioutil.ReadFile(derivePassphraseFile); err != nil {
+			return fmt.Errorf("%w: unable to read passphrase", err)
+		}
+		passphrase = bytes.TrimRight(passphrase, "\r\n")
+	}
+
+	keys := make([]*derivedKey, 0, deriveCount)
+	for i := uint32(0); i < deriveCount; i++ {
+		index := deriveIndex + i
+		key, err := icon.DeriveKey(strings.TrimSpace(mnemonic), string(passphrase), deriveAccount, index)
+		if err != nil {
+			return fmt.Errorf("%w: unable to derive key %d", err, index)
+		}
+		pubKey := key.PublicKey()
+		keys = append(keys, &derivedKey{
+			Path:    icon.DerivationPath(deriveAccount, index),
+			Address: common.NewAccountAddressFromPublicKey(pubKey).String(),
+			PublicKey: &types.PublicKey{
+				Bytes:     pubKey.SerializeCompressed(),
+				CurveType: types.Secp256k1,
+			},
+		})
+	}
+	out, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
@@ -43,6 +43,8 @@ func Execute() error {
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(signCmd)
+	rootCmd.AddCommand(deriveCmd)
 }
 
 // handleSignals handles OS signals so we can ensure we close database
@@ -0,0 +1,166 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/icon-project/goloop/common"
+	"github.com/icon-project/goloop/common/crypto"
+	"github.com/icon-project/goloop/common/wallet"
+	"github.com/icon-project/rosetta-icon/icon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	signCmd = &cobra.Command{
+		Use:   "sign",
+		Short: "Sign the payloads of /construction/payloads with a keystore",
+		Long: `Sign the payloads of a /construction/payloads response with an ICON keystore
+and print the signatures of the /construction/combine request.
+The password of the keystore is read from stdin unless a password file is given.
+It never connects to the network.`,
+		RunE: runSignCmd,
+	}
+
+	signPayloadsFile string
+	signKeyStoreFile string
+	signPasswordFile string
+)
+
+func init() {
+	signCmd.Flags().StringVar(&signPayloadsFile, "payloads", "", "file of the /construction/payloads response")
+	signCmd.Flags().StringVar(&signKeyStoreFile, "keystore", "", "ICON keystore file")
+	signCmd.Flags().StringVar(&signPasswordFile, "password-file", "", "file of the keystore password")
+	_ = signCmd.MarkFlagRequired("payloads")
+	_ = signCmd.MarkFlagRequired("keystore")
+}
+
+func runSignCmd(cmd *cobra.Command, args []string) error {
+	bs, err := ioutil.ReadFile(signPayloadsFile)
+	if err != nil {
+		return fmt.Errorf("%w: unable to read payloads", err)
+	}
+	var payloads types.ConstructionPayloadsResponse
+	if err := json.Unmarshal(bs, &payloads); err != nil {
+		return fmt.Errorf("%w: unable to parse payloads", err)
+	}
+
+	ks, err := ioutil.ReadFile(signKeyStoreFile)
+	if err != nil {
+		return fmt.Errorf("%w: unable to read keystore", err)
+	}
+	pw, err := readPassword()
+	if err != nil {
+		return fmt.Errorf("%w: unable to read password", err)
+	}
+	key, err := wallet.DecryptKeyStore(ks, pw)
+	if err != nil {
+		return fmt.Errorf("%w: unable to decrypt keystore", err)
+	}
+
+	signatures, err := signPayloads(&payloads, key)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(signatures, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+// readPassword reads the password from the password file,
+// or the first line of stdin if there is no password file.
+func readPassword() ([]byte, error) {
+	if len(signPasswordFile) > 0 {
+		pw, err := ioutil.ReadFile(signPasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(pw, "\r\n"), nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+// signPayloads signs the payloads of the unsigned transaction,
+// which must be payloads of the account of the key.
+func signPayloads(payloads *types.ConstructionPayloadsResponse, key *crypto.PrivateKey) ([]*types.Signature, error) {
+	tx, err := icon.ParseTransactionJSON([]byte(payloads.UnsignedTransaction))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse unsigned transaction", err)
+	}
+	hash, err := tx.CalcHash()
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to hash unsigned transaction", err)
+	}
+
+	pubKey := key.PublicKey()
+	address := common.NewAccountAddressFromPublicKey(pubKey).String()
+	if len(payloads.Payloads) == 0 {
+		return nil, errors.New("no payloads to sign")
+	}
+	signatures := make([]*types.Signature, 0, len(payloads.Payloads))
+	for _, p := range payloads.Payloads {
+		if !bytes.Equal(p.Bytes, hash) {
+			return nil, errors.New("payload is not the hash of the unsigned transaction")
+		}
+		if p.AccountIdentifier == nil || p.AccountIdentifier.Address != address {
+			return nil, fmt.Errorf("payload is not for %s", address)
+		}
+		sigType := p.SignatureType
+		if sigType == "" {
+			sigType = types.EcdsaRecovery
+		}
+		if sigType != types.EcdsaRecovery && sigType != types.Ecdsa {
+			return nil, fmt.Errorf("%s is not supported", p.SignatureType)
+		}
+		sig, err := crypto.NewSignature(p.Bytes, key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to sign payload", err)
+		}
+		rsv, err := sig.SerializeRSV()
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to serialize signature", err)
+		}
+		// An ecdsa signature has no recovery id
+		if sigType == types.Ecdsa {
+			rsv = rsv[:icon.SignatureRSLen]
+		}
+		signatures = append(signatures, &types.Signature{
+			SigningPayload: p,
+			PublicKey: &types.PublicKey{
+				Bytes:     pubKey.SerializeCompressed(),
+				CurveType: types.Secp256k1,
+			},
+			SignatureType: sigType,
+			Bytes:         rsv,
+		})
+	}
+	return signatures, nil
+}
@@ -69,6 +69,19 @@ const (
 	// implementation.
 	PortEnv = "PORT"
 
+	// TokensEnv is the environment variable
+	// read to determine the supported IRC-2 tokens.
+	TokensEnv = "TOKENS"
+
+	// StepMarginEnv is the environment variable read to
+	// determine the margin, in percent, added to the steps
+	// estimated for a transaction.
+	StepMarginEnv = "STEP_MARGIN"
+
+	// DefaultStepMargin is the default margin of the
+	// estimated steps in percent.
+	DefaultStepMargin = 10
+
 	// MiddlewareVersion is the version of rosetta-icon
 	MiddlewareVersion = "0.0.4"
 )
@@ -80,6 +93,8 @@ type Configuration struct {
 	GenesisBlock *types.BlockIdentifier
 	Endpoint     string
 	Port         int
+	Tokens       []*icon.Token
+	StepMargin   int
 }
 
 // LoadConfiguration attempts to create a new Configuration
@@ -145,5 +160,28 @@ func LoadConfiguration() (*Configuration, error) {
 	}
 	config.Port = port
 
+	tokens, err := icon.ParseTokens(os.Getenv(TokensEnv))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to parse tokens", err)
+	}
+	for _, t := range tokens {
+		if config.Mode == Offline && t.Decimals == icon.UnknownDecimals {
+			return nil, fmt.Errorf("decimals of token %s must be populated in offline mode", t.Symbol)
+		}
+	}
+	config.Tokens = tokens
+
+	config.StepMargin = DefaultStepMargin
+	if envMargin := os.Getenv(StepMarginEnv); len(envMargin) > 0 {
+		margin, err := strconv.Atoi(envMargin)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to parse step margin %s", err, envMargin)
+		}
+		if margin < 0 {
+			return nil, fmt.Errorf("step margin %d must not be negative", margin)
+		}
+		config.StepMargin = margin
+	}
+
 	return config, nil
 }
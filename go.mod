@@ -7,5 +7,6 @@ require (
 	github.com/fatih/color v1.13.0
 	github.com/icon-project/goloop v1.2.13
 	github.com/spf13/cobra v1.4.0
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 )
@@ -22,6 +22,7 @@ start_chain() {
   if [ "$CID" == "null" ]; then
     # set some runtime configs
     goloop system config rpcRosetta true
+    goloop system config rpcIncludeDebug true
     goloop system config eeInstances 6
 
     NETKEY=${NETWORK,,}
@@ -15,7 +15,9 @@
 package icon
 
 import (
+	"encoding/hex"
 	"fmt"
+	"math/big"
 	"net/http"
 	"strings"
 
@@ -28,10 +30,11 @@ import (
 type Client struct {
 	admin *ClientAdmin
 	v3    *ClientV3
+	debug *ClientDebug
 	rc    *JsonRpcClient
 }
 
-func NewClient(endpoint string) *Client {
+func NewClient(endpoint string, tokens []*Token) *Client {
 	// increase the maximum idle connections to solve
 	// "connect: cannot assign requested address" problem
 	http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = 100
@@ -43,7 +46,8 @@ func NewClient(endpoint string) *Client {
 	}
 	return &Client{
 		admin: NewClientAdmin(endpoint),
-		v3:    NewClientV3(endpoint),
+		v3:    NewClientV3(endpoint, tokens),
+		debug: NewClientDebug(endpoint),
 		rc:    NewJsonRpcClient(client, strings.Join(url, "/")),
 	}
 }
@@ -85,7 +89,7 @@ func (ic *Client) GetBlock(params *RosettaTypes.PartialBlockIdentifier) (*Rosett
 	if err != nil {
 		return nil, err
 	}
-	return &RosettaTypes.Block{
+	block := &RosettaTypes.Block{
 		BlockIdentifier: &RosettaTypes.BlockIdentifier{
 			Index: trace.Index(),
 			Hash:  trace.BlockHash,
@@ -96,7 +100,35 @@ func (ic *Client) GetBlock(params *RosettaTypes.PartialBlockIdentifier) (*Rosett
 		},
 		Timestamp:    trace.TimestampInMillis(),
 		Transactions: transactions,
-	}, nil
+	}
+	if err = ic.populateTokenOperations(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// populateTokenOperations adds the IRC-2 token transfers to the
+// transactions as the trace has only the changes of ICX balances.
+func (ic *Client) populateTokenOperations(block *RosettaTypes.Block) error {
+	if len(ic.v3.tokens) == 0 || len(block.Transactions) == 0 {
+		return nil
+	}
+	trsArray, err := ic.v3.getReceipts(block)
+	if err != nil {
+		return fmt.Errorf("%w: could not get blockReceipts", err)
+	}
+	for i, tx := range block.Transactions {
+		if trsArray[i] == nil || trsArray[i].EventLogs == nil {
+			continue
+		}
+		currencies, err := ic.v3.logCurrencies(trsArray[i].EventLogs)
+		if err != nil {
+			return err
+		}
+		ops := GetTokenOperations(trsArray[i].EventLogs, currencies, int64(len(tx.Operations))-1)
+		tx.Operations = append(tx.Operations, ops...)
+	}
+	return nil
 }
 
 func (ic *Client) getRosettaTrace(param *RosettaTraceParam) (*RosettaTraceResponse, error) {
@@ -142,6 +174,7 @@ func (ic *Client) populateTransaction(bc *BalanceChange) (*RosettaTypes.Transact
 					Value:    "-" + op.IntValue(),
 					Currency: ICXCurrency,
 				},
+				Metadata: GetOperationMeta(op.OpType),
 			}
 			ops = append(ops, fromOp)
 		} else {
@@ -162,6 +195,7 @@ func (ic *Client) populateTransaction(bc *BalanceChange) (*RosettaTypes.Transact
 					Value:    op.IntValue(),
 					Currency: ICXCurrency,
 				},
+				Metadata: GetOperationMeta(op.OpType),
 			}
 			if op.From != "" {
 				toOp.RelatedOperations = []*RosettaTypes.OperationIdentifier{
@@ -202,7 +236,9 @@ func (ic *Client) GetTransaction(params *RosettaTypes.TransactionIdentifier) (*R
 	if err != nil {
 		return nil, fmt.Errorf("%w: could not get transaction result", err)
 	}
-	ic.v3.makeTransactionWithReceipt(tx, txR)
+	if _, err = ic.v3.makeTransactionWithReceipt(tx, txR); err != nil {
+		return nil, err
+	}
 	return tx, nil
 }
 
@@ -235,11 +271,59 @@ func (ic *Client) SendTransaction(tx Transaction) error {
 		return err
 	}
 	if err := ic.v3.sendTransaction(js); err != nil {
-		return err
+		if !IsKnownTransactionError(err) {
+			return err
+		}
+
+		// The transaction was sent before, which is fine
+		// if ICON Node has the same transaction
+		params := &TransactionRPCRequest{
+			Hash: "0x" + hex.EncodeToString(tx.TxHash()),
+		}
+		if _, gErr := ic.v3.getTransaction(params); gErr != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// SimulateTransaction runs the transaction with the debug API
+// instead of sending it. A transaction which fails is reported
+// to use its step limit, the most it can be charged for.
+func (ic *Client) SimulateTransaction(tx Transaction) (*Simulation, error) {
+	sim := &Simulation{}
+	result := &TransactionResult{
+		StatusFlag: RosettaTypes.String(SuccessStatus),
+	}
+	step, err := ic.debug.estimateStep(&tx)
+	if err != nil {
+		if !IsScoreError(err) {
+			return nil, fmt.Errorf("%w: could not estimate step", err)
+		}
+		sim.Failure = err.Error()
+		step = &tx.StepLimit
+		result.StatusFlag = RosettaTypes.String(FailureStatus)
+	}
+	sim.StepUsed = step
+	result.StepUsed = step
+
+	stepPrice, err := ic.v3.getStepPrice()
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not get step price", err)
+	}
+	result.StepPrice = stepPrice
+
+	tx.TxHashV3 = tx.TxHash()
+	rtx, err := ParseTransactionV3(tx)
+	if err != nil {
+		return nil, err
+	}
+	if sim.Transaction, err = ic.v3.makeTransactionWithReceipt(rtx, result); err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
 func (ic *Client) GetDefaultStepCost() (*common.HexInt, error) {
 	res, err := ic.v3.getStepDefaultStepCost()
 	if err != nil {
@@ -248,9 +332,44 @@ func (ic *Client) GetDefaultStepCost() (*common.HexInt, error) {
 	return res, nil
 }
 
+func (ic *Client) GetStepPrice() (*common.HexInt, error) {
+	res, err := ic.v3.getStepPrice()
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ic *Client) EstimateStep(tx Transaction) (*common.HexInt, error) {
+	res, err := ic.debug.estimateStep(&tx)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (ic *Client) GetIScore(address string) (*IScore, error) {
+	res, err := ic.v3.queryIScore(&BalanceRPCRequest{Address: address})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetToken returns the tracked token deployed at the address with
+// its decimals, or nil if the address is not a tracked token.
+func (ic *Client) GetToken(address string) (*Token, error) {
+	t := FindToken(ic.v3.tokens, address)
+	if t == nil {
+		return nil, nil
+	}
+	return ic.v3.resolveToken(t)
+}
+
 func (ic *Client) GetBalance(
 	account *RosettaTypes.AccountIdentifier,
 	block *RosettaTypes.PartialBlockIdentifier,
+	currencies []*RosettaTypes.Currency,
 ) (*RosettaTypes.AccountBalanceResponse, error) {
 	balReq := &BalanceRPCRequest{
 		Address: account.Address,
@@ -259,7 +378,14 @@ func (ic *Client) GetBalance(
 		// result resides in the next block
 		balReq.Height = common.HexInt64{Value: *block.Index + 1}.String()
 	}
-	balance, err := ic.v3.getBalance(balReq)
+
+	var balances []*RosettaTypes.Amount
+	var err error
+	if account.SubAccount != nil {
+		balances, err = ic.getSubAccountBalances(account.SubAccount, balReq, currencies)
+	} else {
+		balances, err = ic.getBalances(balReq, currencies)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -285,11 +411,101 @@ func (ic *Client) GetBalance(
 			Index: blockResp.Height,
 			Hash:  blockResp.BlockHash.String(),
 		},
-		Balances: []*RosettaTypes.Amount{
-			{
-				Value:    balance.Text(10),
-				Currency: ICXCurrency,
-			},
+		Balances: balances,
+	}, nil
+}
+
+func (ic *Client) getBalances(
+	balReq *BalanceRPCRequest,
+	currencies []*RosettaTypes.Currency,
+) ([]*RosettaTypes.Amount, error) {
+	// Token balances are returned only if they are asked, as
+	// a token contract may not exist yet at the height
+	if len(currencies) == 0 {
+		currencies = []*RosettaTypes.Currency{ICXCurrency}
+	}
+
+	var balances []*RosettaTypes.Amount
+	for _, currency := range currencies {
+		var balance *common.HexInt
+		var err error
+		if RosettaTypes.Hash(currency) == RosettaTypes.Hash(ICXCurrency) {
+			balance, err = ic.v3.getBalance(balReq)
+		} else {
+			address, _ := currency.Metadata[ContractAddressKey].(string)
+			var token *Token
+			if token, err = ic.GetToken(address); err != nil {
+				return nil, err
+			}
+			if token == nil || RosettaTypes.Hash(currency) != RosettaTypes.Hash(token.Currency()) {
+				return nil, fmt.Errorf("unsupported currency %s", currency.Symbol)
+			}
+			balance, err = ic.v3.getTokenBalance(address, balReq)
+		}
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, &RosettaTypes.Amount{
+			Value:    balance.Text(10),
+			Currency: currency,
+		})
+	}
+	return balances, nil
+}
+
+// getSubAccountBalances returns the ICX held by the system SCORE
+// for the account. Sub-accounts have balances only in ICX.
+func (ic *Client) getSubAccountBalances(
+	subAccount *RosettaTypes.SubAccountIdentifier,
+	balReq *BalanceRPCRequest,
+	currencies []*RosettaTypes.Currency,
+) ([]*RosettaTypes.Amount, error) {
+	for _, currency := range currencies {
+		if RosettaTypes.Hash(currency) != RosettaTypes.Hash(ICXCurrency) {
+			return nil, fmt.Errorf("unsupported currency %s for %s", currency.Symbol, subAccount.Address)
+		}
+	}
+
+	balance := new(big.Int)
+	switch subAccount.Address {
+	case StakeSubAccount:
+		stake, err := ic.v3.getStake(balReq)
+		if err != nil {
+			return nil, err
+		}
+		if stake.Stake != nil {
+			balance.Set(&stake.Stake.Int)
+		}
+	case UnstakingSubAccount:
+		stake, err := ic.v3.getStake(balReq)
+		if err != nil {
+			return nil, err
+		}
+		balance.Set(stake.Unstaking())
+	case IScoreSubAccount:
+		iscore, err := ic.v3.queryIScore(balReq)
+		if err != nil {
+			return nil, err
+		}
+		if iscore.EstimatedICX != nil {
+			balance.Set(&iscore.EstimatedICX.Int)
+		}
+	case DepositSubAccount:
+		deposit, err := ic.v3.getScoreDepositInfo(balReq)
+		if err != nil {
+			return nil, err
+		}
+		if deposit.AvailableDeposit != nil {
+			balance.Set(&deposit.AvailableDeposit.Int)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sub-account %s", subAccount.Address)
+	}
+
+	return []*RosettaTypes.Amount{
+		{
+			Value:    balance.Text(10),
+			Currency: ICXCurrency,
 		},
 	}, nil
 }
@@ -0,0 +1,60 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icon
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/icon-project/goloop/common"
+)
+
+// ClientDebug is used to call the debug API of ICON Node,
+// which must be enabled with rpcIncludeDebug.
+type ClientDebug struct {
+	*JsonRpcClient
+}
+
+func NewClientDebug(endpoint string) *ClientDebug {
+	client := new(http.Client)
+	url := []string{
+		endpoint,
+		EndpointPrefix,
+		EndpointDebug,
+	}
+	return &ClientDebug{
+		JsonRpcClient: NewJsonRpcClient(client, strings.Join(url, "/")),
+	}
+}
+
+func (c *ClientDebug) estimateStep(tx *Transaction) (*common.HexInt, error) {
+	params, err := tx.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	delete(params, "stepLimit")
+	delete(params, "signature")
+
+	step := &common.HexInt{}
+	jrReq, err := GetRpcRequest("debug_estimateStep", params, -1)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.Request(jrReq, step)
+	if err != nil {
+		return nil, err
+	}
+	return step, nil
+}
@@ -17,9 +17,12 @@ package icon
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/icon-project/goloop/common"
@@ -28,9 +31,13 @@ import (
 
 type ClientV3 struct {
 	*JsonRpcClient
+	tokens []*Token
+
+	mtx      sync.Mutex
+	decimals map[string]int32
 }
 
-func NewClientV3(endpoint string) *ClientV3 {
+func NewClientV3(endpoint string, tokens []*Token) *ClientV3 {
 	client := new(http.Client)
 	url := []string{
 		endpoint,
@@ -39,6 +46,8 @@ func NewClientV3(endpoint string) *ClientV3 {
 	}
 	return &ClientV3{
 		JsonRpcClient: NewJsonRpcClient(client, strings.Join(url, "/")),
+		tokens:        tokens,
+		decimals:      make(map[string]int32),
 	}
 }
 
@@ -74,7 +83,9 @@ func (c *ClientV3) getBlock(params *types.PartialBlockIdentifier) (*types.Block,
 	if err != nil {
 		return nil, fmt.Errorf("%w: could not get blockReceipts", err)
 	}
-	c.makeBlockWithReceipts(rtBlock, trsArray)
+	if _, err = c.makeBlockWithReceipts(rtBlock, trsArray); err != nil {
+		return nil, err
+	}
 	return rtBlock, nil
 }
 
@@ -158,36 +169,34 @@ func (c *ClientV3) getReceipts(block *types.Block) ([]*TransactionResult, error)
 }
 
 func (c *ClientV3) makeBlockWithReceipts(block *types.Block, trsArray []*TransactionResult) (*types.Block, error) {
-	zeroBigInt := new(big.Int)
 	fa := SystemScoreAddress
+	stakes := make(map[string]*big.Int)
 	for index, tx := range block.Transactions {
 		tx = block.Transactions[index]
 		if tx.TransactionIdentifier.Hash == GenesisTxHash {
 			continue
 		}
 		if len(tx.Operations) >= 4 { //general tx(transfer, call, deploy...)
-			su := trsArray[index].StepUsed
-			sp := trsArray[index].StepPrice
-			sd := trsArray[index].StepDetails
 			fa = tx.Operations[0].Account.Address
-			if su.Cmp(zeroBigInt) != 0 {
-				f := new(big.Int).Mul(&su.Int, &sp.Int)
-				fee := f.Text(10)
-				tx.Operations[3].Amount.Value = fee
-				userStep := &su.Int
-				if len(sd) != 0 {
-					userStep = getUserStep(tx.Operations[2].Account.Address, sd)
-				}
-				tx.Operations[2].Amount.Value = "-" + userStep.Mul(userStep, &sp.Int).Text(10)
-			}
+			setFeeOperations(tx, trsArray[index])
 			HandleBugTransaction(tx, fa)
+			err := c.setUnstakeOperations(tx, trsArray[index], block.BlockIdentifier.Index, stakes)
+			if err != nil {
+				return nil, err
+			}
 		}
 		if trsArray[index].EventLogs != nil {
 			ops := GetOperations(fa, trsArray[index].EventLogs, int64(len(tx.Operations))-1)
 			tx.Operations = append(tx.Operations, ops...)
+			currencies, err := c.logCurrencies(trsArray[index].EventLogs)
+			if err != nil {
+				return nil, err
+			}
+			ops = GetTokenOperations(trsArray[index].EventLogs, currencies, int64(len(tx.Operations))-1)
+			tx.Operations = append(tx.Operations, ops...)
 		}
 		for _, op := range tx.Operations {
-			if op.Type != FeeOpType {
+			if op.Type != FeeOpType && op.Type != FSFeeOpType {
 				op.Status = trsArray[index].StatusFlag
 			}
 		}
@@ -233,27 +242,30 @@ func (c *ClientV3) makeTransactionWithReceipt(tx *types.Transaction, txResult *T
 	zeroBigInt := new(big.Int)
 	fa := SystemScoreAddress
 	if len(tx.Operations) >= 4 { //general tx(transfer, call, deploy...)
-		su := txResult.StepUsed
-		sp := txResult.StepPrice
-		sd := txResult.StepDetails
-		if su.Cmp(zeroBigInt) != 0 {
-			f := new(big.Int).Mul(&su.Int, &sp.Int)
-			fee := f.Text(10)
-			tx.Operations[3].Amount.Value = fee
-			userStep := &su.Int
-			if len(sd) != 0 {
-				userStep = getUserStep(tx.Operations[2].Account.Address, sd)
-			}
-			tx.Operations[2].Amount.Value = "-" + userStep.Mul(userStep, &sp.Int).Text(10)
+		if txResult.StepUsed.Cmp(zeroBigInt) != 0 {
 			fa = tx.Operations[0].Account.Address
 		}
+		setFeeOperations(tx, txResult)
+		height := int64(-1)
+		if h, ok := txResult.Height(); ok {
+			height = h
+		}
+		if err := c.setUnstakeOperations(tx, txResult, height, nil); err != nil {
+			return nil, err
+		}
 	}
 	if txResult.EventLogs != nil {
 		ops := GetOperations(fa, txResult.EventLogs, int64(len(tx.Operations))-1)
 		tx.Operations = append(tx.Operations, ops...)
+		currencies, err := c.logCurrencies(txResult.EventLogs)
+		if err != nil {
+			return nil, err
+		}
+		ops = GetTokenOperations(txResult.EventLogs, currencies, int64(len(tx.Operations))-1)
+		tx.Operations = append(tx.Operations, ops...)
 	}
 	for _, op := range tx.Operations {
-		if op.Type != FeeOpType {
+		if op.Type != FeeOpType && op.Type != FSFeeOpType {
 			op.Status = txResult.StatusFlag
 		}
 	}
@@ -272,6 +284,25 @@ func (c *ClientV3) getBalance(param *BalanceRPCRequest) (*common.HexInt, error)
 	return balance, nil
 }
 
+func (c *ClientV3) getTokenBalance(token string, param *BalanceRPCRequest) (*common.HexInt, error) {
+	balance := &common.HexInt{}
+	params := &CallRPCRequest{
+		To:       token,
+		DataType: CallDataType,
+		Data: &CallData{
+			Method: TokenBalanceMethod,
+			Params: map[string]interface{}{
+				"_owner": param.Address,
+			},
+		},
+		Height: param.Height,
+	}
+	if err := c.call(params, balance); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
 func (c *ClientV3) sendTransaction(req interface{}) error {
 	resp := ""
 	jrReq, err := GetRpcRequest("icx_sendTransaction", req, -1)
@@ -305,6 +336,243 @@ func (c *ClientV3) getStepDefaultStepCost() (*common.HexInt, error) {
 	return resp["default"], nil
 }
 
+func (c *ClientV3) getStepPrice() (*common.HexInt, error) {
+	resp := &common.HexInt{}
+	params := &CallRPCRequest{
+		To:       SystemScoreAddress,
+		DataType: CallDataType,
+		Data: &CallData{
+			Method: GetStepPriceMethod,
+		},
+	}
+	if err := c.call(params, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ClientV3) call(param *CallRPCRequest, respPtr interface{}) error {
+	jrReq, err := GetRpcRequest("icx_call", param, -1)
+	if err != nil {
+		return err
+	}
+	_, err = c.Request(jrReq, respPtr)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *ClientV3) queryIScore(param *BalanceRPCRequest) (*IScore, error) {
+	resp := &IScore{}
+	if err := c.callSystemScore(QueryIScoreMethod, param, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ClientV3) getScoreDepositInfo(param *BalanceRPCRequest) (*DepositInfo, error) {
+	resp := &DepositInfo{}
+	if err := c.callSystemScore(GetScoreDepositInfoMethod, param, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *ClientV3) getStake(param *BalanceRPCRequest) (*Stake, error) {
+	resp := &Stake{}
+	if err := c.callSystemScore(GetStakeMethod, param, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// callSystemScore calls a read-only method of the system SCORE
+// which takes the address of an account as its parameter.
+func (c *ClientV3) callSystemScore(method string, param *BalanceRPCRequest, respPtr interface{}) error {
+	params := &CallRPCRequest{
+		To:       SystemScoreAddress,
+		DataType: CallDataType,
+		Data: &CallData{
+			Method: method,
+			Params: map[string]interface{}{
+				"address": param.Address,
+			},
+		},
+		Height: param.Height,
+	}
+	return c.call(params, respPtr)
+}
+
+// resolveToken returns the token with its decimals. The decimals
+// of a token are read once from its contract unless they are
+// configured.
+func (c *ClientV3) resolveToken(t *Token) (*Token, error) {
+	if t.Decimals != UnknownDecimals {
+		return t, nil
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	decimals, ok := c.decimals[t.Address]
+	if !ok {
+		var err error
+		if decimals, err = c.getTokenDecimals(t.Address); err != nil {
+			return nil, fmt.Errorf("%w: could not get decimals of %s", err, t.Symbol)
+		}
+		c.decimals[t.Address] = decimals
+	}
+	token := *t
+	token.Decimals = decimals
+	return &token, nil
+}
+
+// logCurrencies returns the currencies of the tracked tokens by their
+// addresses, only for the tokens which emitted Transfer event logs.
+func (c *ClientV3) logCurrencies(els []*EventLog) (map[string]*types.Currency, error) {
+	currencies := make(map[string]*types.Currency)
+	for _, el := range els {
+		if len(el.Indexed) == 0 || *el.Indexed[0] != tokenTransferSig {
+			continue
+		}
+		if _, ok := currencies[el.Addr]; ok {
+			continue
+		}
+		t := FindToken(c.tokens, el.Addr)
+		if t == nil {
+			continue
+		}
+		token, err := c.resolveToken(t)
+		if err != nil {
+			return nil, err
+		}
+		currencies[el.Addr] = token.Currency()
+	}
+	return currencies, nil
+}
+
+func (c *ClientV3) getTokenDecimals(address string) (int32, error) {
+	resp := &common.HexInt{}
+	params := &CallRPCRequest{
+		To:       address,
+		DataType: CallDataType,
+		Data: &CallData{
+			Method: TokenDecimalsMethod,
+		},
+	}
+	if err := c.call(params, resp); err != nil {
+		return 0, err
+	}
+	if !resp.IsInt64() || resp.Int64() < 0 || resp.Int64() > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid decimals %s", resp.String())
+	}
+	return int32(resp.Int64()), nil
+}
+
+// setUnstakeOperations types the operations of a setStake call
+// lowering the stake of the account as UNSTAKE operations, as the
+// call has only the new stake. The previous stake is the stake at
+// the height of the block, which is the state before its transactions,
+// or at the latest block if it is negative, unless the account set
+// its stake earlier in the block as kept in stakes.
+func (c *ClientV3) setUnstakeOperations(
+	tx *types.Transaction,
+	txResult *TransactionResult,
+	height int64,
+	stakes map[string]*big.Int,
+) error {
+	op := tx.Operations[0]
+	if op.Type != StakeOpType && op.Type != UnstakeOpType {
+		return nil
+	}
+	s, _ := op.Metadata["stake"].(string)
+	value, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil
+	}
+	from := op.Account.Address
+	prev, ok := stakes[from]
+	if !ok && op.Type == StakeOpType {
+		req := &BalanceRPCRequest{Address: from}
+		if height >= 0 {
+			req.Height = common.HexInt64{Value: height}.String()
+		}
+		stake, err := c.getStake(req)
+		if err != nil {
+			return fmt.Errorf("%w: could not get stake of %s", err, from)
+		}
+		prev = new(big.Int)
+		if stake.Stake != nil {
+			prev.Set(&stake.Stake.Int)
+		}
+	}
+	if stakes != nil && *txResult.StatusFlag == SuccessStatus {
+		stakes[from] = value
+	}
+	if op.Type == UnstakeOpType || value.Cmp(prev) >= 0 {
+		return nil
+	}
+	meta := GetOperationMeta(UnstakeOpType)
+	meta["stake"] = s
+	op.Type = UnstakeOpType
+	op.Metadata = meta
+	tx.Operations[1].Type = UnstakeOpType
+	return nil
+}
+
+// setFeeOperations sets the fee paid by the user and appends
+// FS_FEE operations for the steps paid by the contracts which
+// share the fee, which debit the deposit sub-accounts of them.
+func setFeeOperations(tx *types.Transaction, txResult *TransactionResult) {
+	su := txResult.StepUsed
+	sp := txResult.StepPrice
+	sd := txResult.StepDetails
+	if su.Sign() == 0 {
+		return
+	}
+	f := new(big.Int).Mul(&su.Int, &sp.Int)
+	tx.Operations[3].Amount.Value = f.Text(10)
+	from := tx.Operations[2].Account.Address
+	userStep := &su.Int
+	if len(sd) != 0 {
+		userStep = getUserStep(from, sd)
+	}
+	tx.Operations[2].Amount.Value = "-" + new(big.Int).Mul(userStep, &sp.Int).Text(10)
+
+	contracts := make([]string, 0, len(sd))
+	for addr := range sd {
+		if addr != from {
+			contracts = append(contracts, addr)
+		}
+	}
+	sort.Strings(contracts)
+	for _, addr := range contracts {
+		fee := new(big.Int).Mul(&sd[addr].Int, &sp.Int)
+		tx.Operations = append(tx.Operations, &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{
+				Index: int64(len(tx.Operations)),
+			},
+			RelatedOperations: []*types.OperationIdentifier{
+				{
+					Index: tx.Operations[3].OperationIdentifier.Index,
+				},
+			},
+			Type:   FSFeeOpType,
+			Status: types.String(SuccessStatus),
+			Account: &types.AccountIdentifier{
+				Address: addr,
+				SubAccount: &types.SubAccountIdentifier{
+					Address: DepositSubAccount,
+				},
+			},
+			Amount: &types.Amount{
+				Value:    "-" + fee.Text(10),
+				Currency: ICXCurrency,
+			},
+		})
+	}
+}
+
 func getUserStep(from string, stepDetails map[string]*common.HexInt) *big.Int {
 	userUsed := new(big.Int)
 	for f, v := range stepDetails {
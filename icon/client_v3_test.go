@@ -0,0 +1,108 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icon
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const testStaker = "hxe7af5fcfd8dfc67530a01a0e403882687528dfcb"
+
+// newStakeServer returns a server answering getStake with the stake
+// of the height, which is the state before the block of the height.
+func newStakeServer(t *testing.T, stakes map[int64]int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     interface{} `json:"id"`
+			Params struct {
+				Height string `json:"height"`
+			} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return
+		}
+		height, err := strconv.ParseInt(strings.TrimPrefix(req.Params.Height, "0x"), 16, 64)
+		if err != nil {
+			t.Errorf("invalid height %q", req.Params.Height)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%v,"result":{"stake":"0x%x","unstakes":[]}}`,
+			req.ID, stakes[height])
+	}))
+}
+
+func testStakeTransaction(stake int64) *types.Transaction {
+	meta := map[string]interface{}{
+		"stake": big.NewInt(stake).Text(10),
+	}
+	ops := make([]*types.Operation, 4)
+	for i := range ops {
+		ops[i] = &types.Operation{
+			OperationIdentifier: &types.OperationIdentifier{Index: int64(i)},
+			Type:                StakeOpType,
+			Account:             &types.AccountIdentifier{Address: testStaker},
+		}
+	}
+	ops[0].Metadata = meta
+	ops[1].Account.Address = SystemScoreAddress
+	ops[2].Type, ops[3].Type = FeeOpType, FeeOpType
+	return &types.Transaction{Operations: ops}
+}
+
+func TestSetUnstakeOperations(t *testing.T) {
+	// The stake is raised from 50 to 200 in block 9
+	server := newStakeServer(t, map[int64]int64{9: 50, 10: 200})
+	defer server.Close()
+	c := NewClientV3(server.URL, nil)
+	result := &TransactionResult{StatusFlag: types.String(SuccessStatus)}
+
+	for _, tc := range []struct {
+		name   string
+		stake  int64
+		stakes map[string]*big.Int
+		opType string
+	}{
+		{"decrease after the previous block", 100, nil, UnstakeOpType},
+		{"increase after the previous block", 300, nil, StakeOpType},
+		{"decrease in the block", 100, map[string]*big.Int{testStaker: big.NewInt(400)}, UnstakeOpType},
+		{"increase in the block", 300, map[string]*big.Int{testStaker: big.NewInt(10)}, StakeOpType},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tx := testStakeTransaction(tc.stake)
+			if err := c.setUnstakeOperations(tx, result, 10, tc.stakes); err != nil {
+				t.Fatal(err)
+			}
+			for _, op := range tx.Operations[:2] {
+				if op.Type != tc.opType {
+					t.Errorf("operation %d is %s, want %s", op.OperationIdentifier.Index, op.Type, tc.opType)
+				}
+			}
+			if tc.stakes != nil && tc.stakes[testStaker].Int64() != tc.stake {
+				t.Errorf("stake of the block is %s, want %d", tc.stakes[testStaker], tc.stake)
+			}
+		})
+	}
+}
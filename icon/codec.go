@@ -1,7 +1,11 @@
 package icon
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"math/big"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/icon-project/goloop/common"
 )
@@ -43,3 +47,37 @@ func MapNetwork(n string) *common.HexInt {
 		return common.NewHexInt(3)
 	}
 }
+
+func ParseHexInt(s string) (*big.Int, bool) {
+	if strings.HasPrefix(s, "-0x") {
+		v, ok := new(big.Int).SetString(s[3:], 16)
+		if !ok {
+			return nil, false
+		}
+		return v.Neg(v), true
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return nil, false
+	}
+	return new(big.Int).SetString(s[2:], 16)
+}
+
+// EncodeMemo returns the data of a message transaction
+// carrying the memo as hex encoded UTF-8.
+func EncodeMemo(memo string) string {
+	return "0x" + hex.EncodeToString([]byte(memo))
+}
+
+// DecodeMemo returns the memo carried by the data of
+// a message transaction.
+func DecodeMemo(data json.RawMessage) (string, bool) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil || !strings.HasPrefix(s, "0x") {
+		return "", false
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil || !utf8.Valid(b) {
+		return "", false
+	}
+	return string(b), true
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icon
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/icon-project/goloop/common/crypto"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// CoinType is the BIP44 coin type of ICON.
+	CoinType = 74
+
+	// HardenedOffset is the offset of the indexes
+	// of hardened BIP32 child keys.
+	HardenedOffset = uint32(0x80000000)
+
+	bip39Iterations = 2048
+	bip39SaltPrefix = "mnemonic"
+	bip32MasterKey  = "Bitcoin seed"
+)
+
+// secp256k1N is the order of the secp256k1 curve.
+var secp256k1N, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+
+// bip39Indexes are the indexes of the words of the BIP39 wordlist.
+var bip39Indexes = func() map[string]int {
+	indexes := make(map[string]int, len(bip39English))
+	for i, w := range bip39English {
+		indexes[w] = i
+	}
+	return indexes
+}()
+
+// DerivationPath returns the BIP44 path of the key of the
+// account and the index, m/44'/74'/account'/0/index.
+func DerivationPath(account uint32, index uint32) string {
+	return fmt.Sprintf("m/44'/%d'/%d'/0/%d", CoinType, account, index)
+}
+
+// MnemonicToSeed returns the BIP39 seed of the mnemonic, which
+// must be made of the words of the English wordlist with a valid
+// checksum. Only ASCII passphrases are supported, which need no
+// normalization.
+func MnemonicToSeed(mnemonic string, passphrase string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if err := checkMnemonic(words); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(passphrase); i++ {
+		if passphrase[i] >= 0x80 {
+			return nil, errors.New("passphrase must be ASCII")
+		}
+	}
+	mnemonic = strings.Join(words, " ")
+	return pbkdf2.Key([]byte(mnemonic), []byte(bip39SaltPrefix+passphrase), bip39Iterations, sha512.Size, sha512.New), nil
+}
+
+// checkMnemonic checks that the words are in the BIP39 English
+// wordlist and that they end with the checksum of their entropy.
+func checkMnemonic(words []string) error {
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, not %d", len(words))
+	}
+	bits := new(big.Int)
+	for _, w := range words {
+		i, ok := bip39Indexes[w]
+		if !ok {
+			return fmt.Errorf("%q is not a BIP39 word", w)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(i)))
+	}
+
+	// Every 32 bits of entropy have 1 bit of checksum
+	csLen := uint(len(words) * 11 / 33)
+	cs := new(big.Int).And(bits, big.NewInt(1<<csLen-1)).Uint64()
+	entropy := make([]byte, csLen*4)
+	bits.Rsh(bits, csLen).FillBytes(entropy)
+	h := sha256.Sum256(entropy)
+	if uint64(h[0]>>(8-csLen)) != cs {
+		return errors.New("invalid mnemonic checksum")
+	}
+	return nil
+}
+
+// DeriveKey returns the private key of the account and the index
+// derived from the mnemonic along m/44'/74'/account'/0/index.
+func DeriveKey(mnemonic string, passphrase string, account uint32, index uint32) (*crypto.PrivateKey, error) {
+	if account >= HardenedOffset || index >= HardenedOffset {
+		return nil, fmt.Errorf("account and index must be less than %d", HardenedOffset)
+	}
+	seed, err := MnemonicToSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	path := []uint32{
+		44 + HardenedOffset,
+		CoinType + HardenedOffset,
+		account + HardenedOffset,
+		0,
+		index,
+	}
+	key, chainCode := masterKey(seed)
+	for _, i := range path {
+		if key, chainCode, err = childKey(key, chainCode, i); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ParsePrivateKey(key)
+}
+
+func masterKey(seed []byte) ([]byte, []byte) {
+	mac := hmac.New(sha512.New, []byte(bip32MasterKey))
+	mac.Write(seed)
+	I := mac.Sum(nil)
+	return I[:32], I[32:]
+}
+
+// childKey returns the BIP32 child private key and chain code
+// of the index.
+func childKey(key []byte, chainCode []byte, index uint32) ([]byte, []byte, error) {
+	mac := hmac.New(sha512.New, chainCode)
+	if index >= HardenedOffset {
+		mac.Write([]byte{0})
+		mac.Write(key)
+	} else {
+		pk, err := crypto.ParsePrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		mac.Write(pk.PublicKey().SerializeCompressed())
+	}
+	var i [4]byte
+	binary.BigEndian.PutUint32(i[:], index)
+	mac.Write(i[:])
+	I := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(I[:32])
+	if il.Cmp(secp256k1N) >= 0 {
+		return nil, nil, fmt.Errorf("invalid child key %d", index)
+	}
+	k := il.Add(il, new(big.Int).SetBytes(key))
+	k.Mod(k, secp256k1N)
+	if k.Sign() == 0 {
+		return nil, nil, fmt.Errorf("invalid child key %d", index)
+	}
+	child := make([]byte, 32)
+	k.FillBytes(child)
+	return child, I[32:], nil
+}
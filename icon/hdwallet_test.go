@@ -0,0 +1,95 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icon
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/icon-project/goloop/common"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// TestMnemonicToSeed checks the seeds of the BIP39 test vectors
+// of github.com/trezor/python-mnemonic.
+func TestMnemonicToSeed(t *testing.T) {
+	seed, err := MnemonicToSeed(testMnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if got := hex.EncodeToString(seed); got != want {
+		t.Errorf("seed is %s, want %s", got, want)
+	}
+}
+
+func TestMnemonicToSeedInvalid(t *testing.T) {
+	for name, mnemonic := range map[string]string{
+		"empty":    "",
+		"length":   "abandon abandon abandon",
+		"word":     strings.Replace(testMnemonic, "about", "aboot", 1),
+		"checksum": strings.Repeat("abandon ", 12),
+	} {
+		if _, err := MnemonicToSeed(mnemonic, ""); err == nil {
+			t.Errorf("%s mnemonic is accepted", name)
+		}
+	}
+}
+
+// TestChildKey checks m/0H of the BIP32 test vector 1.
+func TestChildKey(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	key, chainCode := masterKey(seed)
+	if got, want := hex.EncodeToString(key), "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"; got != want {
+		t.Errorf("master key is %s, want %s", got, want)
+	}
+	key, chainCode, err := childKey(key, chainCode, HardenedOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(key), "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"; got != want {
+		t.Errorf("key of m/0H is %s, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(chainCode), "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141"; got != want {
+		t.Errorf("chain code of m/0H is %s, want %s", got, want)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	for _, tc := range []struct {
+		passphrase string
+		index      uint32
+		key        string
+		address    string
+	}{
+		{"", 0, "eeb91671f8b48097332d7b3fb307a6653e3a01ea7c06c558af53e9e803fbfdca", "hx8e825d342f8dd76af9dba2f53464c9781833d39f"},
+		{"", 1, "02d3e5d171bce862dcc7b052a557070811d8c8d4866b6c8634e3521f14631448", "hxbc5da62b1c7f613fedd36031e1115f6b3ade2724"},
+		{"TREZOR", 0, "563a6a45d7e91282b877a27772defc20990f614e92a581ecfa93fb909750ffba", "hxe9500555659c59e188f1aef3f397870321240125"},
+	} {
+		key, err := DeriveKey(testMnemonic, tc.passphrase, 0, tc.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(key.Bytes()); got != tc.key {
+			t.Errorf("key of %s is %s, want %s", DerivationPath(0, tc.index), got, tc.key)
+		}
+		addr := common.NewAccountAddressFromPublicKey(key.PublicKey())
+		if got := addr.String(); got != tc.address {
+			t.Errorf("address of %s is %s, want %s", DerivationPath(0, tc.index), got, tc.address)
+		}
+	}
+}
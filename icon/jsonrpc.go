@@ -17,10 +17,12 @@ package icon
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/icon-project/goloop/server/jsonrpc"
@@ -32,6 +34,65 @@ const (
 	typeApplicationJSON = "application/json"
 )
 
+// JSON-RPC error codes of ICON Node. A transaction which is
+// already in the pool or in a block is rejected with a server
+// or system error, told apart by its message.
+const (
+	ErrorCodeMethodNotFound jsonrpc.ErrorCode = -32601
+	ErrorCodeServer         jsonrpc.ErrorCode = -32000
+	ErrorCodeSystem         jsonrpc.ErrorCode = -31000
+	ErrorCodeScore          jsonrpc.ErrorCode = -30000
+)
+
+// knownTransactionMessages are the messages of the errors which
+// icx_sendTransaction returns for a transaction which ICON Node
+// already has, ErrDuplicateTransaction and ErrCommittedTransaction
+// of goloop's service/errors.go.
+var knownTransactionMessages = []string{
+	"DuplicateTransaction",
+	"CommittedTransaction",
+}
+
+// IsKnownTransactionError reports whether the error is returned
+// by icx_sendTransaction for a transaction which is already in
+// the pool or in a block.
+func IsKnownTransactionError(err error) bool {
+	var jrErr *jsonrpc.Error
+	if !errors.As(err, &jrErr) {
+		return false
+	}
+	if jrErr.Code != ErrorCodeServer && jrErr.Code != ErrorCodeSystem {
+		return false
+	}
+	for _, m := range knownTransactionMessages {
+		if strings.HasPrefix(jrErr.Message, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsScoreError reports whether the error is returned for
+// a transaction which fails in its execution.
+func IsScoreError(err error) bool {
+	var jrErr *jsonrpc.Error
+	if !errors.As(err, &jrErr) {
+		return false
+	}
+	return jrErr.Code <= ErrorCodeScore && jrErr.Code > ErrorCodeSystem
+}
+
+// IsUnavailableError reports whether the error is returned when
+// the API is not reachable or the method is not enabled, which
+// is not a JSON-RPC error or a method not found error.
+func IsUnavailableError(err error) bool {
+	var jrErr *jsonrpc.Error
+	if !errors.As(err, &jrErr) {
+		return true
+	}
+	return jrErr.Code == ErrorCodeMethodNotFound
+}
+
 type JsonRpcClient struct {
 	hc           *http.Client
 	Endpoint     string
@@ -1,9 +1,13 @@
 package icon
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"math/big"
+	"strings"
 
 	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/icon-project/goloop/common/crypto"
 )
 
 const (
@@ -15,8 +19,33 @@ const (
 	burnSig2         = "ICXBurned(int)"
 	burnSig3         = "ICXBurnedV2(Address,int,int)"
 	depositWithdrawn = "DepositWithdrawn(bytes,Address,int,int)"
+	tokenTransferSig = "Transfer(Address,Address,int,bytes)"
+
+	// tokenZeroAddress is the sender of minted tokens
+	// and the receiver of burned tokens.
+	tokenZeroAddress = "hx0000000000000000000000000000000000000000"
 )
 
+// lockupSubAccounts maps the operations which move ICX between
+// the liquid balance and a sub-account to the sub-account.
+var lockupSubAccounts = map[string]string{
+	StakeOpType:   StakeSubAccount,
+	UnstakeOpType: UnstakingSubAccount,
+	ClaimOpType:   IScoreSubAccount,
+	RewardOpType:  IScoreSubAccount,
+}
+
+// GetOperationMeta returns the metadata of the operation type
+// telling lockups from liquid moves.
+func GetOperationMeta(opType string) map[string]interface{} {
+	if sa, ok := lockupSubAccounts[opType]; ok {
+		return map[string]interface{}{
+			LockupKey: sa,
+		}
+	}
+	return nil
+}
+
 func ParseGenesisOperationsV2(tx GenesisTransaction) ([]*types.Operation, error) {
 	var ops []*types.Operation
 	for _, account := range tx.Accounts {
@@ -157,6 +186,42 @@ func ParseOperationsV3(transaction Transaction) ([]*types.Operation, error) {
 		return ops, nil
 	}
 	opType := getOPType(dataType, transaction.To.String())
+	var meta map[string]interface{}
+	if opType == CallOpType && transaction.ToAddr() == SystemScoreAddress {
+		opType, meta = getSystemCallOperation(transaction.Data)
+	}
+	if opType == CallOpType {
+		meta = getCallMeta(transaction)
+	}
+	if opType == DeployOpType {
+		meta = getDeployMeta(transaction.Data)
+	}
+	if opType == DepositOpType {
+		opType = getDepositOPType(transaction.Data)
+	}
+	if opType == FSWithdrawOpType {
+		meta = getWithdrawMeta(transaction)
+	}
+	// A message with a memo is a transfer with the memo
+	if memo, ok := transaction.Memo(); ok {
+		opType = TransferOpType
+		meta = map[string]interface{}{
+			MemoKey: memo,
+		}
+	}
+
+	// Calls without ICX do not move any balance
+	var fromAmount, toAmount *types.Amount
+	if transaction.Value != nil || opType == TransferOpType || opType == MessageOpType {
+		fromAmount = &types.Amount{
+			Value:    "-" + transaction.Values(),
+			Currency: ICXCurrency,
+		}
+		toAmount = &types.Amount{
+			Value:    transaction.Values(),
+			Currency: ICXCurrency,
+		}
+	}
 
 	fromOp := &types.Operation{
 		OperationIdentifier: &types.OperationIdentifier{
@@ -167,10 +232,8 @@ func ParseOperationsV3(transaction Transaction) ([]*types.Operation, error) {
 		Account: &types.AccountIdentifier{
 			Address: transaction.FromAddr(),
 		},
-		Amount: &types.Amount{
-			Value:    "-" + transaction.Values(),
-			Currency: ICXCurrency,
-		},
+		Amount:   fromAmount,
+		Metadata: meta,
 	}
 
 	ops = append(ops, fromOp)
@@ -190,10 +253,12 @@ func ParseOperationsV3(transaction Transaction) ([]*types.Operation, error) {
 		Account: &types.AccountIdentifier{
 			Address: transaction.ToAddr(),
 		},
-		Amount: &types.Amount{
-			Value:    transaction.Values(),
-			Currency: ICXCurrency,
-		},
+		Amount: toAmount,
+	}
+	if opType == FSDepositOpType || opType == FSWithdrawOpType {
+		toOp.Account.SubAccount = &types.SubAccountIdentifier{
+			Address: DepositSubAccount,
+		}
 	}
 
 	ops = append(ops, toOp)
@@ -346,6 +411,75 @@ func GetOperations(fa string, els []*EventLog, lastOpIndex int64) []*types.Opera
 	return ops
 }
 
+// GetTokenOperations returns the operations of the IRC-2 token
+// transfers in the event logs. currencies maps the addresses of
+// the tracked token contracts to their currencies.
+func GetTokenOperations(els []*EventLog, currencies map[string]*types.Currency, lastOpIndex int64) []*types.Operation {
+	ops := make([]*types.Operation, 0)
+	for _, el := range els {
+		if len(el.Indexed) == 0 || *el.Indexed[0] != tokenTransferSig {
+			continue
+		}
+		currency, ok := currencies[el.Addr]
+		if !ok {
+			continue
+		}
+		// some tokens do not index the value of the transfer
+		values := append(append([]*string{}, el.Indexed[1:]...), el.Data...)
+		if len(values) < 3 || values[0] == nil || values[1] == nil || values[2] == nil {
+			continue
+		}
+		value, ok := ParseHexInt(*values[2])
+		if !ok {
+			continue
+		}
+		from, to := *values[0], *values[1]
+		if from != tokenZeroAddress {
+			ops = append(ops, &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: lastOpIndex + 1,
+				},
+				Type:   TransferOpType,
+				Status: types.String(SuccessStatus),
+				Account: &types.AccountIdentifier{
+					Address: from,
+				},
+				Amount: &types.Amount{
+					Value:    "-" + value.Text(10),
+					Currency: currency,
+				},
+			})
+			lastOpIndex += 1
+		}
+		if to != tokenZeroAddress {
+			toOp := &types.Operation{
+				OperationIdentifier: &types.OperationIdentifier{
+					Index: lastOpIndex + 1,
+				},
+				Type:   TransferOpType,
+				Status: types.String(SuccessStatus),
+				Account: &types.AccountIdentifier{
+					Address: to,
+				},
+				Amount: &types.Amount{
+					Value:    value.Text(10),
+					Currency: currency,
+				},
+			}
+			if from != tokenZeroAddress {
+				toOp.RelatedOperations = []*types.OperationIdentifier{
+					{
+						Index: lastOpIndex,
+					},
+				}
+			}
+			ops = append(ops, toOp)
+			lastOpIndex += 1
+		}
+	}
+	return ops
+}
+
 func getClaimOps(fa string, el *EventLog, lastOpIndex int64) []*types.Operation {
 	value := new(big.Int)
 	value.SetString((*el.Data[1])[2:], 16)
@@ -383,6 +517,7 @@ func getClaimOps(fa string, el *EventLog, lastOpIndex int64) []*types.Operation
 			Value:    value.Text(10),
 			Currency: ICXCurrency,
 		},
+		Metadata: GetOperationMeta(ClaimOpType),
 	})
 	return ops
 }
@@ -428,6 +563,162 @@ func getDepositWithdrawn(el *EventLog, lastOpIndex int64) *types.Operation {
 	return op
 }
 
+// getSystemCallOperation returns the operation type and metadata
+// of a call to the system SCORE.
+func getSystemCallOperation(data json.RawMessage) (string, map[string]interface{}) {
+	var call CallData
+	if err := json.Unmarshal(data, &call); err != nil {
+		return CallOpType, nil
+	}
+	switch call.Method {
+	case SetStakeMethod:
+		s, _ := call.Params["value"].(string)
+		value, ok := ParseHexInt(s)
+		if !ok {
+			return StakeOpType, GetOperationMeta(StakeOpType)
+		}
+		opType := StakeOpType
+		if value.Sign() == 0 {
+			opType = UnstakeOpType
+		}
+		meta := GetOperationMeta(opType)
+		meta["stake"] = value.Text(10)
+		return opType, meta
+	case SetDelegationMethod:
+		delegations, ok := getDelegations(call.Params)
+		if !ok {
+			return CallOpType, nil
+		}
+		return DelegateOpType, map[string]interface{}{
+			"delegations": delegations,
+		}
+	case ClaimIScoreMethod:
+		return ClaimOpType, GetOperationMeta(ClaimOpType)
+	case RegisterPRepMethod:
+		return RegPRepOpType, nil
+	default:
+		return CallOpType, nil
+	}
+}
+
+// getDelegations returns the delegations of a setDelegation
+// call with their values in decimal.
+func getDelegations(params map[string]interface{}) ([]*Delegation, bool) {
+	var p struct {
+		Delegations []*Delegation `json:"delegations"`
+	}
+	if err := UnmarshalJSONMap(params, &p); err != nil {
+		return nil, false
+	}
+	delegations := make([]*Delegation, len(p.Delegations))
+	for i, d := range p.Delegations {
+		value, ok := ParseHexInt(d.Value)
+		if !ok {
+			return nil, false
+		}
+		delegations[i] = &Delegation{
+			Address: d.Address,
+			Value:   value.Text(10),
+		}
+	}
+	return delegations, true
+}
+
+// getCallMeta returns the metadata of a call to a SCORE,
+// which is the metadata of the CALL construction intent.
+func getCallMeta(transaction Transaction) map[string]interface{} {
+	meta := map[string]interface{}{
+		"to": transaction.ToAddr(),
+	}
+	var call CallData
+	if err := json.Unmarshal(transaction.Data, &call); err == nil {
+		meta["method"] = call.Method
+		if call.Params != nil {
+			meta["params"] = call.Params
+		}
+	}
+	return meta
+}
+
+// getDeployMeta returns the metadata of a deploy transaction,
+// which carries the hash of the content instead of the content.
+func getDeployMeta(data json.RawMessage) map[string]interface{} {
+	var deploy DeployData
+	if err := json.Unmarshal(data, &deploy); err != nil {
+		return nil
+	}
+	meta := map[string]interface{}{
+		"content_type": deploy.ContentType,
+	}
+	if content, err := hex.DecodeString(strings.TrimPrefix(deploy.Content, "0x")); err == nil {
+		meta["content_hash"] = "0x" + hex.EncodeToString(crypto.SHA3Sum256(content))
+	}
+	if deploy.Params != nil {
+		meta["params"] = deploy.Params
+	}
+	return meta
+}
+
+// GetTokenTransferOperations returns the operations of the token
+// transfer called by the transaction, as GetTokenOperations returns
+// them from the event log of the transfer.
+func GetTokenTransferOperations(transaction Transaction, currency *types.Currency, lastOpIndex int64) []*types.Operation {
+	var call CallData
+	if err := json.Unmarshal(transaction.Data, &call); err != nil || call.Method != TokenTransferMethod {
+		return nil
+	}
+	to, ok1 := call.Params["_to"].(string)
+	value, ok2 := call.Params["_value"].(string)
+	if !ok1 || !ok2 {
+		return nil
+	}
+	sig, from := tokenTransferSig, transaction.FromAddr()
+	el := &EventLog{
+		Addr:    transaction.ToAddr(),
+		Indexed: []*string{&sig, &from, &to, &value},
+	}
+	currencies := map[string]*types.Currency{
+		transaction.ToAddr(): currency,
+	}
+	return GetTokenOperations([]*EventLog{el}, currencies, lastOpIndex)
+}
+
+// getDepositOPType returns the operation type of a transaction
+// which adds to or withdraws from the deposit of a contract.
+func getDepositOPType(data json.RawMessage) string {
+	var deposit DepositData
+	if err := json.Unmarshal(data, &deposit); err != nil {
+		return DepositOpType
+	}
+	switch deposit.Action {
+	case DepositAddAction:
+		return FSDepositOpType
+	case DepositWithdrawAction:
+		return FSWithdrawOpType
+	default:
+		return DepositOpType
+	}
+}
+
+// getWithdrawMeta returns the metadata of a transaction which
+// withdraws the deposit of a contract by its id or amount.
+func getWithdrawMeta(transaction Transaction) map[string]interface{} {
+	meta := map[string]interface{}{
+		"to": transaction.ToAddr(),
+	}
+	var deposit DepositData
+	if err := json.Unmarshal(transaction.Data, &deposit); err != nil {
+		return meta
+	}
+	if len(deposit.ID) > 0 {
+		meta["id"] = deposit.ID
+	}
+	if amount, ok := ParseHexInt(deposit.Amount); ok {
+		meta["amount"] = amount.Text(10)
+	}
+	return meta
+}
+
 func getOPType(dataType string, toAddress string) string {
 	switch dataType {
 	case DeployDataType:
@@ -0,0 +1,70 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icon
+
+import (
+	"fmt"
+
+	"github.com/icon-project/goloop/common"
+	"github.com/icon-project/goloop/common/crypto"
+)
+
+const (
+	// SignatureLen is the length of a signature
+	// with its recovery id.
+	SignatureLen = 65
+
+	// SignatureRSLen is the length of a signature
+	// without its recovery id.
+	SignatureRSLen = 64
+)
+
+// ParseSignature parses a signature with its recovery id.
+func ParseSignature(sig []byte) (*common.Signature, error) {
+	if len(sig) != SignatureLen {
+		return nil, fmt.Errorf("signature must be %d bytes", SignatureLen)
+	}
+	s, err := crypto.ParseSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	return &common.Signature{Signature: s}, nil
+}
+
+// RecoverSignature returns the signature with the recovery id
+// of a signature without it, which is the recovery id of
+// the public key of the signer.
+func RecoverSignature(hash []byte, rs []byte, pubKey []byte) (*common.Signature, error) {
+	if len(rs) != SignatureRSLen {
+		return nil, fmt.Errorf("signature must be %d bytes", SignatureRSLen)
+	}
+	pk, err := crypto.ParsePublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, SignatureLen)
+	copy(sig, rs)
+	for v := byte(0); v < 4; v++ {
+		sig[SignatureRSLen] = v
+		s, err := crypto.ParseSignature(sig)
+		if err != nil {
+			continue
+		}
+		if rpk, err := s.RecoverPublicKey(hash); err == nil && rpk.Equal(pk) {
+			return &common.Signature{Signature: s}, nil
+		}
+	}
+	return nil, fmt.Errorf("signature is not signed by the public key")
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/coinbase/rosetta-sdk-go/types"
+)
+
+const (
+	// ContractAddressKey is the key of the token contract
+	// address in the metadata of a token currency.
+	ContractAddressKey = "contract_address"
+
+	TokenTransferMethod = "transfer"
+	TokenDecimalsMethod = "decimals"
+	TokenBalanceMethod  = "balanceOf"
+
+	// UnknownDecimals is the decimals of a token which
+	// has to be read from its contract.
+	UnknownDecimals = int32(-1)
+)
+
+// Token is an IRC-2 token contract.
+type Token struct {
+	Symbol   string
+	Address  string
+	Decimals int32
+}
+
+// Currency returns the Rosetta currency of the token.
+func (t *Token) Currency() *types.Currency {
+	return &types.Currency{
+		Symbol:   t.Symbol,
+		Decimals: t.Decimals,
+		Metadata: map[string]interface{}{
+			ContractAddressKey: t.Address,
+		},
+	}
+}
+
+// ParseTokens parses a comma separated list of tokens in the
+// SYMBOL:ADDRESS[:DECIMALS] form. The decimals of a token
+// are read from its contract if they are omitted.
+func ParseTokens(s string) ([]*Token, error) {
+	var tokens []*Token
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) != 2 && len(fields) != 3 {
+			return nil, fmt.Errorf("invalid token %s", entry)
+		}
+		if err := CheckAddress(fields[1]); err != nil || !IsContract(fields[1]) {
+			return nil, fmt.Errorf("invalid token address %s", fields[1])
+		}
+		token := &Token{
+			Symbol:   fields[0],
+			Address:  fields[1],
+			Decimals: UnknownDecimals,
+		}
+		if len(fields) == 3 {
+			decimals, err := strconv.ParseInt(fields[2], 10, 32)
+			if err != nil || decimals < 0 {
+				return nil, fmt.Errorf("invalid token decimals %s", fields[2])
+			}
+			token.Decimals = int32(decimals)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// FindToken returns the token deployed at the address
+// or nil if it is not one of the tokens.
+func FindToken(tokens []*Token, address string) *Token {
+	for _, t := range tokens {
+		if t.Address == address {
+			return t
+		}
+	}
+	return nil
+}
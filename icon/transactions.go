@@ -36,10 +36,10 @@ func ParseTransactions(txArray []json.RawMessage) ([]*types.Transaction, error)
 		if err := json.Unmarshal(bs, &transaction); err != nil {
 			return nil, err
 		}
-		if transaction.Fee == nil {
-			tx, _ = ParseTransactionV3(transaction)
-		} else {
+		if transaction.IsV2() {
 			tx, _ = ParseTransactionV2(transaction)
+		} else {
+			tx, _ = ParseTransactionV3(transaction)
 		}
 		transactions = append(transactions, tx)
 	}
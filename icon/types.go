@@ -17,6 +17,10 @@ package icon
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
 
 	"github.com/coinbase/rosetta-sdk-go/asserter"
 	"github.com/coinbase/rosetta-sdk-go/types"
@@ -43,11 +47,40 @@ var (
 		FSFeeOpType,
 		StakeOpType,
 		UnstakeOpType,
+		DelegateOpType,
 		ClaimOpType,
 		GhostOpType,
 		RewardOpType,
 		RegPRepOpType,
 		MessageOpType,
+		CallOpType,
+		DeployOpType,
+	}
+
+	// BalanceExemptions are the sub-accounts whose balances
+	// change without operations, such as unlocked stakes
+	// and accrued I-Score.
+	BalanceExemptions = []*types.BalanceExemption{
+		{
+			SubAccountAddress: types.String(StakeSubAccount),
+			Currency:          ICXCurrency,
+			ExemptionType:     types.BalanceDynamic,
+		},
+		{
+			SubAccountAddress: types.String(UnstakingSubAccount),
+			Currency:          ICXCurrency,
+			ExemptionType:     types.BalanceDynamic,
+		},
+		{
+			SubAccountAddress: types.String(IScoreSubAccount),
+			Currency:          ICXCurrency,
+			ExemptionType:     types.BalanceDynamic,
+		},
+		{
+			SubAccountAddress: types.String(DepositSubAccount),
+			Currency:          ICXCurrency,
+			ExemptionType:     types.BalanceDynamic,
+		},
 	}
 
 	// OperationStatuses are all supported operation statuses.
@@ -88,6 +121,7 @@ const (
 
 	EndpointPrefix  = "api"
 	EndpointVersion = "v3"
+	EndpointDebug   = "v3d"
 	EndpointAdmin   = "admin"
 	EndpointRosetta = "rosetta"
 
@@ -112,6 +146,7 @@ const (
 	FSFeeOpType      = "FS_FEE"
 	StakeOpType      = "STAKE"
 	UnstakeOpType    = "UNSTAKE"
+	DelegateOpType   = "DELEGATE"
 	ClaimOpType      = "CLAIM"
 	GhostOpType      = "GHOST"
 	RewardOpType     = "REWARD"
@@ -133,10 +168,51 @@ const (
 	CallDataType     = "call"
 	DepositDataType  = "deposit"
 
+	// ContentTypeZip and ContentTypeJava are the content
+	// types of Python and Java contracts.
+	ContentTypeZip  = "application/zip"
+	ContentTypeJava = "application/java"
+
+	DepositAddAction      = "add"
+	DepositWithdrawAction = "withdraw"
+
+	SetStakeMethod      = "setStake"
+	SetDelegationMethod = "setDelegation"
+	ClaimIScoreMethod   = "claimIScore"
+	QueryIScoreMethod   = "queryIScore"
+	GetStakeMethod      = "getStake"
+	RegisterPRepMethod  = "registerPRep"
+	GetStepPriceMethod  = "getStepPrice"
+
+	GetScoreDepositInfoMethod = "getScoreDepositInfo"
+
+	// The delegated ICX is not a sub-account, as it is
+	// a part of the stake and would be counted twice.
+	StakeSubAccount     = "stake"
+	UnstakingSubAccount = "unstaking"
+	IScoreSubAccount    = "iscore"
+
+	// DepositSubAccount is the sub-account of the deposit
+	// of a contract which pays the fees it shares.
+	DepositSubAccount = "deposit"
+
+	// LockupKey is the operation metadata key of the sub-account
+	// which ICX is locked into or released from. Operations
+	// without it move ICX between liquid balances.
+	LockupKey = "lockup"
+
+	// MemoKey is the metadata key of the memo
+	// of a message transaction.
+	MemoKey = "memo"
+
 	SuccessStatus = "SUCCESS"
 	FailureStatus = "FAIL"
 
 	GenesisTxHash = "0x0000000000000000000000000000000000000000000000000000000000000000"
+
+	// TxTimestampThreshold is the maximum difference between the
+	// timestamp of a transaction and the time of ICON Node.
+	TxTimestampThreshold = 5 * time.Minute
 )
 
 type BlockRPCRequest struct {
@@ -153,6 +229,46 @@ type BalanceRPCRequest struct {
 	Height  string `json:"height,omitempty"`
 }
 
+type CallRPCRequest struct {
+	To       string    `json:"to"`
+	DataType string    `json:"dataType"`
+	Data     *CallData `json:"data"`
+	Height   string    `json:"height,omitempty"`
+}
+
+type IScore struct {
+	BlockHeight  *common.HexInt `json:"blockHeight"`
+	IScore       *common.HexInt `json:"iscore"`
+	EstimatedICX *common.HexInt `json:"estimatedICX"`
+}
+
+type Unstake struct {
+	Unstake            *common.HexInt `json:"unstake"`
+	UnstakeBlockHeight *common.HexInt `json:"unstakeBlockHeight"`
+	RemainingBlocks    *common.HexInt `json:"remainingBlocks"`
+}
+
+type Stake struct {
+	Stake    *common.HexInt `json:"stake"`
+	Unstakes []*Unstake     `json:"unstakes"`
+}
+
+func (s *Stake) Unstaking() *big.Int {
+	sum := new(big.Int)
+	for _, u := range s.Unstakes {
+		if u.Unstake != nil {
+			sum.Add(sum, &u.Unstake.Int)
+		}
+	}
+	return sum
+}
+
+// DepositInfo is the deposit of a contract
+// which pays the fees it shares.
+type DepositInfo struct {
+	AvailableDeposit *common.HexInt `json:"availableDeposit"`
+}
+
 type Block struct {
 	BlockHash          common.HexBytes   `json:"block_hash"`
 	Version            string            `json:"version"`
@@ -232,6 +348,10 @@ type Transaction struct {
 	TxHashV3  common.HexBytes   `json:"txHash,omitempty"`
 	TxHashV2  common.HexBytes   `json:"tx_hash,omitempty"`
 	Method    string            `json:"method,omitempty"`
+
+	// raw is the JSON of a v2 transaction, whose
+	// hash is computed from the JSON fields.
+	raw json.RawMessage
 }
 
 func (tx *Transaction) Values() string {
@@ -273,6 +393,9 @@ func (tx *Transaction) MetaV3() map[string]interface{} {
 		"data":      tx.Data,
 		"dataType":  &tx.DataType,
 	}
+	if memo, ok := tx.Memo(); ok {
+		meta[MemoKey] = memo
+	}
 
 	if tx.GetDataType() == "Base" {
 		return meta
@@ -284,6 +407,20 @@ func (tx *Transaction) MetaV3() map[string]interface{} {
 	}
 }
 
+// Memo returns the memo of a message transaction.
+func (tx *Transaction) Memo() (string, bool) {
+	if tx.GetDataType() != MessageDataType {
+		return "", false
+	}
+	return DecodeMemo(tx.Data)
+}
+
+// Deadline returns the time in microseconds until which
+// ICON Node accepts the transaction.
+func (tx *Transaction) Deadline() int64 {
+	return tx.Timestamp.Value + int64(TxTimestampThreshold/time.Microsecond)
+}
+
 func (tx *Transaction) GetDataType() string {
 	defaultType := [5]string{"call", "deploy", "message", "base", "deposit"}
 
@@ -324,7 +461,17 @@ func (tx *Transaction) ToJSON() (map[string]interface{}, error) {
 	return jso, nil
 }
 
+// IsV2 reports whether the transaction is an ICON1 v2 transaction,
+// which has a fee instead of a step limit.
+func (tx *Transaction) IsV2() bool {
+	return tx.Fee != nil
+}
+
 func (tx *Transaction) CalcHash() ([]byte, error) {
+	if tx.IsV2() {
+		return tx.calcHashV2()
+	}
+
 	// sha := sha3.New256()
 	sha := bytes.NewBuffer(nil)
 	sha.Write([]byte("icx_sendTransaction"))
@@ -392,6 +539,39 @@ func (tx *Transaction) CalcHash() ([]byte, error) {
 	return crypto.SHA3Sum256(sha.Bytes()), nil
 }
 
+// calcHashV2 returns the hash of a v2 transaction, which is computed
+// from all the fields of its JSON but method, tx_hash and signature
+// in the order of their names.
+func (tx *Transaction) calcHashV2() ([]byte, error) {
+	if tx.raw == nil {
+		return nil, fmt.Errorf("v2 transaction is hashed from its JSON")
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(tx.raw, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "method")
+	delete(fields, "tx_hash")
+	delete(fields, "signature")
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sha := bytes.NewBuffer(nil)
+	sha.Write([]byte("icx_sendTransaction"))
+	for _, k := range keys {
+		bs, err := transaction.SerializeValue(fields[k])
+		if err != nil {
+			return nil, err
+		}
+		sha.Write([]byte("." + k + "."))
+		sha.Write(bs)
+	}
+	return crypto.SHA3Sum256(sha.Bytes()), nil
+}
+
 func (tx *Transaction) VerifySignature() error {
 	pk, err := tx.Signature.RecoverPublicKey(tx.TxHash())
 	if err != nil {
@@ -421,6 +601,40 @@ func ParseV3JSON(js []byte) (*Transaction, error) {
 	return tx, nil
 }
 
+// ParseTransactionJSON parses a v2 or v3 transaction.
+func ParseTransactionJSON(js []byte) (*Transaction, error) {
+	tx := new(Transaction)
+	if err := json.Unmarshal(js, tx); err != nil {
+		return nil, transaction.InvalidFormat.Wrapf(err, "Invalid json for transaction(%s)", string(js))
+	}
+	if tx.IsV2() {
+		tx.raw = append(json.RawMessage{}, js...)
+	}
+	return tx, nil
+}
+
+type CallData struct {
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type DeployData struct {
+	ContentType string                 `json:"contentType"`
+	Content     string                 `json:"content"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+}
+
+type DepositData struct {
+	Action string `json:"action"`
+	ID     string `json:"id,omitempty"`
+	Amount string `json:"amount,omitempty"`
+}
+
+type Delegation struct {
+	Address string `json:"address"`
+	Value   string `json:"value"`
+}
+
 type EventLog struct {
 	Addr    string    `json:"scoreAddress"`
 	Indexed []*string `json:"indexed"`
@@ -445,6 +659,26 @@ type TransactionResult struct {
 	StepDetails        map[string]*common.HexInt `json:"stepUsedDetails"`
 }
 
+// Height returns the height of the block of the transaction.
+func (r *TransactionResult) Height() (int64, bool) {
+	if r.BlockHeight == nil {
+		return 0, false
+	}
+	var height common.HexInt64
+	if err := json.Unmarshal(*r.BlockHeight, &height); err != nil {
+		return 0, false
+	}
+	return height.Value, true
+}
+
+// Simulation is the result of a transaction run
+// without being broadcast.
+type Simulation struct {
+	Transaction *types.Transaction
+	StepUsed    *common.HexInt
+	Failure     string
+}
+
 type RosettaTraceParam struct {
 	Tx     string `json:"tx,omitempty"`
 	Block  string `json:"block,omitempty"`
@@ -0,0 +1,74 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icon
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+// testV2Transaction is a v2 transfer whose hash is the SHA3-256 of
+// icx_sendTransaction.fee.0x2386f26fc10000.from.hxbe25…ce11.nonce.8367273
+// .timestamp.1523327456264040.to.hx5bfd…b7cd.value.0xde0b6b3a7640000
+const testV2Transaction = `{
+	"method": "icx_sendTransaction",
+	"from": "hxbe258ceb872e08851f1f59694dac2558708ece11",
+	"to": "hx5bfdb090f43a808005ffc27c25b213145e80b7cd",
+	"value": "0xde0b6b3a7640000",
+	"fee": "0x2386f26fc10000",
+	"timestamp": "1523327456264040",
+	"nonce": "8367273",
+	"tx_hash": "0x536703a549ef6ce0255f0704644a34814b34c292a507210396cccc28b2ab6ece"
+}`
+
+const testV2Hash = "536703a549ef6ce0255f0704644a34814b34c292a507210396cccc28b2ab6ece"
+
+func TestCalcHashV2(t *testing.T) {
+	tx, err := ParseTransactionJSON([]byte(testV2Transaction))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !tx.IsV2() {
+		t.Fatal("transaction with a fee is not v2")
+	}
+	h, err := tx.CalcHash()
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if got := hex.EncodeToString(h); got != testV2Hash {
+		t.Errorf("hash is %s, want %s", got, testV2Hash)
+	}
+}
+
+func TestParseTransactionsV2(t *testing.T) {
+	txs, err := ParseTransactions([]json.RawMessage{
+		json.RawMessage(testV2Transaction),
+	})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(txs) != 1 {
+		t.Fatalf("got %d transactions, want 1", len(txs))
+	}
+	// A v2 transaction has a fee operation, which a v3 one has not
+	var fee bool
+	for _, op := range txs[0].Operations {
+		fee = fee || op.Type == FeeOpType
+	}
+	if !fee {
+		t.Error("transaction with a fee is not parsed as v2")
+	}
+}
@@ -50,6 +50,7 @@ func (s *AccountAPIService) AccountBalance(
 	balanceResponse, err := s.client.GetBalance(
 		request.AccountIdentifier,
 		request.BlockIdentifier,
+		request.Currencies,
 	)
 	if err != nil {
 		return nil, wrapErr(ErrUnableToGetBalance, err)
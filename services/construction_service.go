@@ -15,13 +15,14 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"time"
 
-	"github.com/coinbase/rosetta-sdk-go/parser"
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/icon-project/goloop/common"
 	"github.com/icon-project/goloop/common/crypto"
@@ -70,43 +71,12 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 	ctx context.Context,
 	request *types.ConstructionPreprocessRequest,
 ) (*types.ConstructionPreprocessResponse, *types.Error) {
-	descriptions := &parser.Descriptions{
-		OperationDescriptions: []*parser.OperationDescription{
-			{
-				Type: icon.TransferOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.NegativeAmountSign,
-					Currency: icon.ICXCurrency,
-				},
-			},
-			{
-				Type: icon.TransferOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.PositiveAmountSign,
-					Currency: icon.ICXCurrency,
-				},
-			},
-		},
-		ErrUnmatched: true,
-	}
-
-	m, err := parser.MatchOperations(descriptions, request.Operations)
+	it, err := parseIntent(request.Operations, s.token)
 	if err != nil {
 		return nil, wrapErr(ErrUnclearIntent, err)
 	}
-
-	f, _ := m[0].First()
-	fa := f.Account.Address
-	t, _ := m[1].First()
-	ta := t.Account.Address
+	fa := it.From
+	ta := it.To
 
 	// Ensure valid from address
 	e := icon.CheckAddress(fa)
@@ -120,8 +90,54 @@ func (s *ConstructionAPIService) ConstructionPreprocess(
 		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", ta))
 	}
 
-	preprocessOutput := &options{
-		From: fa,
+	// Ensure valid addresses in the transaction data
+	for _, a := range it.Accounts {
+		if e = icon.CheckAddress(a); e != nil {
+			return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", a))
+		}
+	}
+
+	preprocessOutput, err := it.options()
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	// Pass the timestamp and the nonce to /construction/metadata
+	var pm preprocessMetadata
+	if err := icon.UnmarshalJSONMap(request.Metadata, &pm); err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+	preprocessOutput.Timestamp = pm.Timestamp
+	preprocessOutput.Nonce = pm.Nonce
+
+	// Pass the signature type of the payload to /construction/payloads
+	if _, err := signatureType(pm.SignatureType); err != nil {
+		return nil, wrapErr(ErrUnsupportedSignatureType, err)
+	}
+	preprocessOutput.SignatureType = pm.SignatureType
+
+	// Pass the fee limits to /construction/metadata
+	if len(request.MaxFee) > 0 {
+		maxFee := new(big.Int)
+		for _, amount := range request.MaxFee {
+			if types.Hash(amount.Currency) != types.Hash(icon.ICXCurrency) {
+				return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("max fee must be in %s", icon.ICXSymbol))
+			}
+			value, err := types.AmountValue(amount)
+			if err != nil {
+				return nil, wrapErr(ErrUnclearIntent, err)
+			}
+			maxFee.Add(maxFee, value)
+		}
+		preprocessOutput.MaxFee = &common.HexInt{Int: *maxFee}
+	}
+	// A multiplier below 1 would set the step limit below the steps
+	// which the transaction is estimated to use
+	if m := request.SuggestedFeeMultiplier; m != nil {
+		if *m < 1 {
+			return nil, wrapErr(ErrUnclearIntent, fmt.Errorf("fee multiplier %f is less than 1", *m))
+		}
+		preprocessOutput.FeeMultiplier = m
 	}
 
 	marshaled, err := icon.MarshalJSONMap(preprocessOutput)
@@ -154,7 +170,63 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 	}
 
 	metadata := &metadata{
-		res,
+		DefaultStepCost: res,
+		StepLimit:       res,
+		Timestamp:       input.Timestamp,
+		Nonce:           input.Nonce,
+		SignatureType:   input.SignatureType,
+	}
+	if metadata.Timestamp == nil {
+		metadata.Timestamp = &common.HexInt64{Value: time.Now().UnixNano() / int64(time.Microsecond)}
+	}
+	if metadata.Nonce == nil {
+		metadata.Nonce = common.NewHexInt(1)
+	}
+
+	// Estimate the steps of the transaction, which falls back
+	// to the default step cost only if the debug API is unavailable
+	uTx, err := input.intent().transaction(res, icon.MapNetwork(s.config.Network.Network))
+	if err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+	uTx.Timestamp = *metadata.Timestamp
+	uTx.Nonce = metadata.Nonce
+	step, err := s.client.EstimateStep(*uTx)
+	switch {
+	case err == nil:
+		margin := big.NewInt(int64(100 + s.config.StepMargin))
+		limit := new(big.Int).Mul(&step.Int, margin)
+		limit.Div(limit, big.NewInt(100))
+		metadata.StepLimit = &common.HexInt{Int: *limit}
+	case !icon.IsUnavailableError(err):
+		return nil, wrapErr(ErrUnableToEstimateStep, err)
+	}
+
+	if input.FeeMultiplier != nil {
+		limit := new(big.Float).SetInt(&metadata.StepLimit.Int)
+		limit.Mul(limit, big.NewFloat(*input.FeeMultiplier))
+		scaled, _ := limit.Int(nil)
+		metadata.StepLimit = &common.HexInt{Int: *scaled}
+	}
+
+	stepPrice, err := s.client.GetStepPrice()
+	if err != nil {
+		return nil, wrapErr(ErrUnableToGetStepPrice, err)
+	}
+	metadata.StepPrice = stepPrice
+	fee := new(big.Int).Mul(&metadata.StepLimit.Int, &stepPrice.Int)
+	if input.MaxFee != nil && fee.Cmp(&input.MaxFee.Int) > 0 {
+		return nil, wrapErr(ErrExceededMaxFee, fmt.Errorf("fee %s exceeds max fee %s", fee.Text(10), input.MaxFee.Text(10)))
+	}
+
+	// Report the I-Score which will be claimed
+	if input.OpType == icon.ClaimOpType {
+		iscore, err := s.client.GetIScore(input.From)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToGetIScore, err)
+		}
+		metadata.IScore = iscore.IScore
+		metadata.EstimatedICX = iscore.EstimatedICX
 	}
 
 	metadataMap, err := icon.MarshalJSONMap(metadata)
@@ -164,6 +236,12 @@ func (s *ConstructionAPIService) ConstructionMetadata(
 
 	return &types.ConstructionMetadataResponse{
 		Metadata: metadataMap,
+		SuggestedFee: []*types.Amount{
+			{
+				Value:    fee.Text(10),
+				Currency: icon.ICXCurrency,
+			},
+		},
 	}, nil
 }
 
@@ -172,41 +250,10 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 	ctx context.Context,
 	request *types.ConstructionPayloadsRequest,
 ) (*types.ConstructionPayloadsResponse, *types.Error) {
-	d := &parser.Descriptions{
-		OperationDescriptions: []*parser.OperationDescription{
-			{
-				Type: icon.TransferOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.NegativeAmountSign,
-					Currency: icon.ICXCurrency,
-				},
-			},
-			{
-				Type: icon.TransferOpType,
-				Account: &parser.AccountDescription{
-					Exists: true,
-				},
-				Amount: &parser.AmountDescription{
-					Exists:   true,
-					Sign:     parser.PositiveAmountSign,
-					Currency: icon.ICXCurrency,
-				},
-			},
-		},
-		ErrUnmatched: true,
-	}
-	m, err := parser.MatchOperations(d, request.Operations)
+	it, err := parseIntent(request.Operations, s.token)
 	if err != nil {
 		return nil, wrapErr(ErrUnclearIntent, err)
 	}
-
-	// Required Fields for constructing a ICON transaction
-	tOp, amount := m[1].First()
-	ta := tOp.Account.Address
 	nid := icon.MapNetwork(s.config.Network.Network)
 
 	// stepLimit
@@ -221,17 +268,22 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 	}
 
 	// Additional Fields for constructing custom ICON tx struct
-	fOp, _ := m[0].First()
-	fa := fOp.Account.Address
-	uTx := &icon.Transaction{
-		Version:   common.HexUint16{Value: 3},
-		From:      *common.MustNewAddressFromString(fa),
-		To:        *common.MustNewAddressFromString(ta),
-		Value:     &common.HexInt{Int: *amount},
-		StepLimit: *meta.DefaultStepCost,
-		Timestamp: common.HexInt64{Value: time.Now().UnixNano() / int64(time.Microsecond)},
-		NID:       nid,
-		Nonce:     common.NewHexInt(1),
+	fa := it.From
+	stepLimit := meta.StepLimit
+	if stepLimit == nil {
+		stepLimit = meta.DefaultStepCost
+	}
+	uTx, err := it.transaction(stepLimit, nid)
+	if err != nil {
+		return nil, wrapErr(ErrUnclearIntent, err)
+	}
+	uTx.Timestamp = common.HexInt64{Value: time.Now().UnixNano() / int64(time.Microsecond)}
+	if meta.Timestamp != nil {
+		uTx.Timestamp = *meta.Timestamp
+	}
+	uTx.Nonce = common.NewHexInt(1)
+	if meta.Nonce != nil {
+		uTx.Nonce = meta.Nonce
 	}
 
 	h, err := uTx.CalcHash()
@@ -239,13 +291,20 @@ func (s *ConstructionAPIService) ConstructionPayloads(
 		return nil, ErrUnclearIntent
 	}
 
+	sigType, err := signatureType(meta.SignatureType)
+	if err != nil {
+		return nil, wrapErr(ErrUnsupportedSignatureType, err)
+	}
 	payload := &types.SigningPayload{
 		AccountIdentifier: &types.AccountIdentifier{Address: fa},
 		Bytes:             h,
-		SignatureType:     types.EcdsaRecovery,
+		SignatureType:     sigType,
 	}
 
-	unsignedTxJSON, err := json.Marshal(uTx)
+	unsignedTxJSON, err := json.Marshal(&unsignedTransaction{
+		Transaction: *uTx,
+		Deadline:    &common.HexInt64{Value: uTx.Deadline()},
+	})
 	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
@@ -262,24 +321,62 @@ func (s *ConstructionAPIService) ConstructionCombine(
 	ctx context.Context,
 	request *types.ConstructionCombineRequest,
 ) (*types.ConstructionCombineResponse, *types.Error) {
-	var unsignedTx icon.Transaction
+	var ut unsignedTransaction
 	var err error
-	if err = json.Unmarshal([]byte(request.UnsignedTransaction), &unsignedTx); err != nil {
+	if err = json.Unmarshal([]byte(request.UnsignedTransaction), &ut); err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
+	unsignedTx := ut.Transaction
+
+	// Reject the transaction which ICON Node would not accept. The
+	// deadline given with it can only bring the deadline forward.
+	deadline := unsignedTx.Deadline()
+	if ut.Deadline != nil && ut.Deadline.Value < deadline {
+		deadline = ut.Deadline.Value
+	}
+	if now := time.Now().UnixNano() / int64(time.Microsecond); now > deadline {
+		return nil, wrapErr(ErrTransactionExpired, fmt.Errorf("transaction expired at %d", deadline))
+	}
 
-	var sig common.Signature
-	sigBytes := request.Signatures[0].Bytes
-	sig.Signature, err = crypto.ParseSignature(sigBytes)
+	if len(request.Signatures) != 1 {
+		return nil, wrapErr(ErrSignatureInvalid, fmt.Errorf("expected 1 signature but got %d", len(request.Signatures)))
+	}
+	h, err := unsignedTx.CalcHash()
 	if err != nil {
-		return nil, wrapErr(ErrSignatureInvalid, err)
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
+	// Every signature must sign the payload of the transaction
 	signedTx := unsignedTx
-	signedTx.Signature = &sig
+	for _, rs := range request.Signatures {
+		p := rs.SigningPayload
+		if !bytes.Equal(p.Bytes, h) {
+			return nil, wrapErr(ErrSignatureInvalid, fmt.Errorf("signature is not for the payload of the transaction"))
+		}
+		if p.AccountIdentifier != nil && p.AccountIdentifier.Address != unsignedTx.FromAddr() {
+			return nil, wrapErr(ErrSignatureInvalid, fmt.Errorf("signature is not for %s", unsignedTx.FromAddr()))
+		}
+
+		var sig *common.Signature
+		switch rs.SignatureType {
+		case types.EcdsaRecovery:
+			sig, err = icon.ParseSignature(rs.Bytes)
+		case types.Ecdsa:
+			if rs.PublicKey == nil {
+				return nil, wrapErr(ErrSignatureInvalid, fmt.Errorf("public key is required for %s signatures", types.Ecdsa))
+			}
+			sig, err = icon.RecoverSignature(h, rs.Bytes, rs.PublicKey.Bytes)
+		default:
+			return nil, wrapErr(ErrUnsupportedSignatureType, fmt.Errorf("%s is not supported", rs.SignatureType))
+		}
+		if err != nil {
+			return nil, wrapErr(ErrSignatureInvalid, err)
+		}
+		signedTx.Signature = sig
+	}
 
 	if err = signedTx.VerifySignature(); err != nil {
-		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+		return nil, wrapErr(ErrSignatureMismatch, err)
 	}
 
 	signedTxJSON, err := json.Marshal(signedTx)
@@ -297,11 +394,15 @@ func (s *ConstructionAPIService) ConstructionHash(
 	ctx context.Context,
 	request *types.ConstructionHashRequest,
 ) (*types.TransactionIdentifierResponse, *types.Error) {
-	signedTx := &icon.Transaction{}
-	if err := json.Unmarshal([]byte(request.SignedTransaction), signedTx); err != nil {
+	signedTx, err := icon.ParseTransactionJSON([]byte(request.SignedTransaction))
+	if err != nil {
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
-	h := "0x" + hex.EncodeToString(signedTx.TxHash())
+	hash, err := signedTx.CalcHash()
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	h := "0x" + hex.EncodeToString(hash)
 
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: &types.TransactionIdentifier{
@@ -315,62 +416,31 @@ func (s *ConstructionAPIService) ConstructionParse(
 	ctx context.Context,
 	request *types.ConstructionParseRequest,
 ) (*types.ConstructionParseResponse, *types.Error) {
-	var tx icon.Transaction
-	if !request.Signed {
-		err := json.Unmarshal([]byte(request.Transaction), &tx)
-		if err != nil {
-			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
-		}
-	} else {
-		err := json.Unmarshal([]byte(request.Transaction), &tx)
-		if err != nil {
-			return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
-		}
-
+	tx, err := icon.ParseTransactionJSON([]byte(request.Transaction))
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	err := icon.CheckAddress(tx.From.String())
+	err = icon.CheckAddress(tx.From.String())
 	if err != nil {
 		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", tx.From.String()))
 	}
 
-	err = icon.CheckAddress(tx.From.String())
+	err = icon.CheckAddress(tx.To.String())
 	if err != nil {
-		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", tx.To))
+		return nil, wrapErr(ErrInvalidAddress, fmt.Errorf("%s is not a valid address", tx.To.String()))
 	}
 
-	ops := []*types.Operation{
-		{
-			Type: icon.TransferOpType,
-			OperationIdentifier: &types.OperationIdentifier{
-				Index: 0,
-			},
-			Account: &types.AccountIdentifier{
-				Address: tx.From.String(),
-			},
-			Amount: &types.Amount{
-				Value:    "-" + tx.Values(),
-				Currency: icon.ICXCurrency,
-			},
-		},
-		{
-			Type: icon.TransferOpType,
-			OperationIdentifier: &types.OperationIdentifier{
-				Index: 1,
-			},
-			RelatedOperations: []*types.OperationIdentifier{
-				{
-					Index: 0,
-				},
-			},
-			Account: &types.AccountIdentifier{
-				Address: tx.To.String(),
-			},
-			Amount: &types.Amount{
-				Value:    tx.Values(),
-				Currency: icon.ICXCurrency,
-			},
-		},
+	ops, err := parseOperations(tx, s.token)
+	if err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+
+	var meta map[string]interface{}
+	if memo, ok := tx.Memo(); ok {
+		meta = map[string]interface{}{
+			icon.MemoKey: memo,
+		}
 	}
 
 	var resp *types.ConstructionParseResponse
@@ -382,11 +452,13 @@ func (s *ConstructionAPIService) ConstructionParse(
 					Address: tx.From.String(),
 				},
 			},
+			Metadata: meta,
 		}
 	} else {
 		resp = &types.ConstructionParseResponse{
 			Operations:               ops,
 			AccountIdentifierSigners: []*types.AccountIdentifier{},
+			Metadata:                 meta,
 		}
 	}
 	return resp, nil
@@ -406,8 +478,23 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
 	}
 
-	if err := s.client.SendTransaction(*signedTx); err != nil {
-		return nil, wrapErr(ErrBroadcastFailed, err)
+	// Reject the transaction which ICON Node would not accept
+	if signedTx.Signature == nil {
+		return nil, wrapErr(ErrSignatureMismatch, fmt.Errorf("transaction is not signed"))
+	}
+	if err := signedTx.VerifySignature(); err != nil {
+		return nil, wrapErr(ErrSignatureMismatch, err)
+	}
+	nid := icon.MapNetwork(s.config.Network.Network)
+	if signedTx.NID == nil || signedTx.NID.Cmp(&nid.Int) != 0 {
+		return nil, wrapErr(ErrNetworkMismatch, fmt.Errorf("nid must be %s", nid.String()))
+	}
+	now := time.Now().UnixNano() / int64(time.Microsecond)
+	if now > signedTx.Deadline() {
+		return nil, wrapErr(ErrTransactionExpired, fmt.Errorf("transaction expired at %d", signedTx.Deadline()))
+	}
+	if threshold := int64(icon.TxTimestampThreshold / time.Microsecond); signedTx.Timestamp.Value > now+threshold {
+		return nil, wrapErr(ErrInvalidTimestamp, fmt.Errorf("timestamp %d is ahead of %d", signedTx.Timestamp.Value, now))
 	}
 
 	h := "0x" + hex.EncodeToString(signedTx.TxHash())
@@ -415,7 +502,68 @@ func (s *ConstructionAPIService) ConstructionSubmit(
 		Hash: h,
 	}
 
+	// Run the transaction without sending it if it is asked
+	var so submitOptions
+	if err := json.Unmarshal([]byte(request.SignedTransaction), &so); err != nil {
+		return nil, wrapErr(ErrUnableToParseIntermediateResult, err)
+	}
+	if so.Simulate {
+		sim, err := s.client.SimulateTransaction(*signedTx)
+		if err != nil {
+			return nil, wrapErr(ErrUnableToSimulate, err)
+		}
+		meta := map[string]interface{}{
+			"simulated":   true,
+			"step_used":   sim.StepUsed,
+			"operations":  sim.Transaction.Operations,
+			"limitations": simulationLimitations,
+		}
+		if len(sim.Failure) > 0 {
+			meta["failure"] = sim.Failure
+		}
+		return &types.TransactionIdentifierResponse{
+			TransactionIdentifier: txIdentifier,
+			Metadata:              meta,
+		}, nil
+	}
+
+	if err := s.client.SendTransaction(*signedTx); err != nil {
+		return nil, wrapErr(ErrBroadcastFailed, err)
+	}
+
 	return &types.TransactionIdentifierResponse{
 		TransactionIdentifier: txIdentifier,
 	}, nil
 }
+
+// signatureType returns the signature type of a signing payload,
+// which is ecdsa_recovery unless ecdsa is asked.
+func signatureType(t types.SignatureType) (types.SignatureType, error) {
+	switch t {
+	case "":
+		return types.EcdsaRecovery, nil
+	case types.Ecdsa, types.EcdsaRecovery:
+		return t, nil
+	default:
+		return "", fmt.Errorf("%s is not supported", t)
+	}
+}
+
+// simulationLimitations tell the clients what the result of
+// a simulated transaction lacks, as debug_estimateStep only
+// returns the steps of the transaction.
+var simulationLimitations = []string{
+	"operations lack the internal ICX, token and claim transfers, as no event logs are returned",
+	"step_used of a failed transaction is its step limit, not the steps it would be charged",
+}
+
+// token returns the tracked token deployed at the address with its
+// decimals, or nil if the address is not a tracked token. Only the
+// online mode reads the decimals missing in the configuration.
+func (s *ConstructionAPIService) token(address string) (*icon.Token, error) {
+	token := icon.FindToken(s.config.Tokens, address)
+	if token == nil || token.Decimals != icon.UnknownDecimals {
+		return token, nil
+	}
+	return s.client.GetToken(address)
+}
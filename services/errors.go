@@ -33,6 +33,16 @@ var (
 		ErrInvalidAddress,
 		ErrWrongHashOrIndex,
 		ErrUnableToGetBalance,
+		ErrUnableToGetIScore,
+		ErrUnableToGetStepPrice,
+		ErrExceededMaxFee,
+		ErrTransactionExpired,
+		ErrSignatureMismatch,
+		ErrNetworkMismatch,
+		ErrInvalidTimestamp,
+		ErrUnableToSimulate,
+		ErrUnsupportedSignatureType,
+		ErrUnableToEstimateStep,
 	}
 
 	// ErrUnimplemented is returned when an endpoint
@@ -115,6 +125,81 @@ var (
 		Code:    14,
 		Message: "Unable to get balance",
 	}
+
+	// ErrUnableToGetIScore is returned when it is not possible
+	// to query the I-Score of an account.
+	ErrUnableToGetIScore = &types.Error{
+		Code:      15,
+		Message:   "Unable to get I-Score",
+		Retriable: true,
+	}
+
+	// ErrUnableToGetStepPrice is returned when it is not possible
+	// to get the current step price.
+	ErrUnableToGetStepPrice = &types.Error{
+		Code:      16,
+		Message:   "Unable to get step price",
+		Retriable: true,
+	}
+
+	// ErrExceededMaxFee is returned when the fee of a
+	// transaction would exceed the max fee of the request.
+	ErrExceededMaxFee = &types.Error{
+		Code:      17,
+		Message:   "Fee exceeds max fee",
+		Retriable: true,
+	}
+
+	// ErrTransactionExpired is returned when a transaction
+	// is past its deadline.
+	ErrTransactionExpired = &types.Error{
+		Code:    18,
+		Message: "Transaction expired",
+	}
+
+	// ErrSignatureMismatch is returned when the signature
+	// of a transaction is not signed by its sender.
+	ErrSignatureMismatch = &types.Error{
+		Code:    19,
+		Message: "Signature does not match sender",
+	}
+
+	// ErrNetworkMismatch is returned when a transaction
+	// is for another network.
+	ErrNetworkMismatch = &types.Error{
+		Code:    20,
+		Message: "Network mismatch",
+	}
+
+	// ErrInvalidTimestamp is returned when the timestamp
+	// of a transaction is ahead of the time of ICON Node.
+	ErrInvalidTimestamp = &types.Error{
+		Code:    21,
+		Message: "Transaction timestamp is in the future",
+	}
+
+	// ErrUnableToSimulate is returned when it is not
+	// possible to run a transaction without sending it.
+	ErrUnableToSimulate = &types.Error{
+		Code:      22,
+		Message:   "Unable to simulate transaction",
+		Retriable: true,
+	}
+
+	// ErrUnsupportedSignatureType is returned when a signature
+	// is neither ecdsa_recovery nor ecdsa.
+	ErrUnsupportedSignatureType = &types.Error{
+		Code:    23,
+		Message: "Unsupported signature type",
+	}
+
+	// ErrUnableToEstimateStep is returned when the steps of
+	// a transaction cannot be estimated, such as when it
+	// would fail in its execution.
+	ErrUnableToEstimateStep = &types.Error{
+		Code:    24,
+		Message: "Unable to estimate steps",
+	}
 )
 
 // wrapErr adds details to the types.Error provided. We use a function
@@ -0,0 +1,715 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/icon-project/goloop/common"
+	"github.com/icon-project/rosetta-icon/icon"
+)
+
+// tokenFinder returns the tracked token deployed at the address
+// with its decimals, or nil if the address is not a tracked token.
+type tokenFinder func(address string) (*icon.Token, error)
+
+// intent is the ICON transaction described by the operations
+// given to /construction/preprocess and /construction/payloads.
+type intent struct {
+	OpType   string
+	From     string
+	To       string
+	Value    *big.Int
+	DataType *string
+	Data     interface{}
+
+	// Accounts are the other addresses referenced by the
+	// transaction data, such as delegated P-Reps.
+	Accounts []string
+}
+
+// transferMetadata is the metadata of a TRANSFER operation.
+type transferMetadata struct {
+	Memo string `json:"memo,omitempty"`
+}
+
+// callMetadata is the metadata of a CALL operation.
+type callMetadata struct {
+	To     string                 `json:"to"`
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// stakeMetadata is the metadata of a STAKE operation.
+type stakeMetadata struct {
+	Stake string `json:"stake"`
+}
+
+// deployMetadata is the metadata of a DEPLOY operation.
+type deployMetadata struct {
+	ContentType string                 `json:"content_type"`
+	Content     string                 `json:"content"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+}
+
+// withdrawMetadata is the metadata of a FS_WITHDRAW operation.
+// The whole deposit is withdrawn if neither the id nor the
+// amount is given.
+type withdrawMetadata struct {
+	To     string `json:"to"`
+	ID     string `json:"id,omitempty"`
+	Amount string `json:"amount,omitempty"`
+}
+
+// delegateMetadata is the metadata of a DELEGATE operation.
+type delegateMetadata struct {
+	Delegations []*icon.Delegation `json:"delegations"`
+}
+
+var (
+	// transferDescriptions describes a transfer of ICX or
+	// of one of the configured IRC-2 tokens.
+	transferDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.TransferOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: true,
+					Sign:   parser.NegativeAmountSign,
+				},
+			},
+			{
+				Type: icon.TransferOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: true,
+					Sign:   parser.PositiveAmountSign,
+				},
+			},
+		},
+		OppositeAmounts: [][]int{{0, 1}},
+		ErrUnmatched:    true,
+	}
+
+	// stakeDescriptions describes a setStake call. The stake in
+	// the metadata is the new total stake of the account.
+	stakeDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.StakeOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: false,
+				},
+				Metadata: []*parser.MetadataDescription{
+					{
+						Key:       "stake",
+						ValueKind: reflect.String,
+					},
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	// unstakeDescriptions describes a setStake call which
+	// unstakes the whole stake of the account.
+	unstakeDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.UnstakeOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: false,
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	// delegateDescriptions describes a setDelegation call. The
+	// delegations replace all the delegations of the account.
+	delegateDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.DelegateOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: false,
+				},
+				Metadata: []*parser.MetadataDescription{
+					{
+						Key:       "delegations",
+						ValueKind: reflect.Slice,
+					},
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	// callDescriptions describes a call to any SCORE. The amount
+	// is optional and is the ICX sent along with the call.
+	callDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.CallOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Metadata: []*parser.MetadataDescription{
+					{
+						Key:       "to",
+						ValueKind: reflect.String,
+					},
+					{
+						Key:       "method",
+						ValueKind: reflect.String,
+					},
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	// deployDescriptions describes the deployment of a contract
+	// with its hex encoded content.
+	deployDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.DeployOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: false,
+				},
+				Metadata: []*parser.MetadataDescription{
+					{
+						Key:       "content_type",
+						ValueKind: reflect.String,
+					},
+					{
+						Key:       "content",
+						ValueKind: reflect.String,
+					},
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	// depositDescriptions describes the ICX added to
+	// the deposit of a contract.
+	depositDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.FSDepositOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists:   true,
+					Sign:     parser.NegativeAmountSign,
+					Currency: icon.ICXCurrency,
+				},
+			},
+			{
+				Type: icon.FSDepositOpType,
+				Account: &parser.AccountDescription{
+					Exists:            true,
+					SubAccountExists:  true,
+					SubAccountAddress: icon.DepositSubAccount,
+				},
+				Amount: &parser.AmountDescription{
+					Exists:   true,
+					Sign:     parser.PositiveAmountSign,
+					Currency: icon.ICXCurrency,
+				},
+			},
+		},
+		OppositeAmounts: [][]int{{0, 1}},
+		ErrUnmatched:    true,
+	}
+
+	// withdrawDescriptions describes the withdrawal
+	// of a deposit of a contract.
+	withdrawDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.FSWithdrawOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: false,
+				},
+				Metadata: []*parser.MetadataDescription{
+					{
+						Key:       "to",
+						ValueKind: reflect.String,
+					},
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+
+	claimDescriptions = &parser.Descriptions{
+		OperationDescriptions: []*parser.OperationDescription{
+			{
+				Type: icon.ClaimOpType,
+				Account: &parser.AccountDescription{
+					Exists: true,
+				},
+				Amount: &parser.AmountDescription{
+					Exists: false,
+				},
+			},
+		},
+		ErrUnmatched: true,
+	}
+)
+
+// parseIntent matches the operations with one of the supported
+// intents and returns the transaction they describe.
+func parseIntent(ops []*types.Operation, findToken tokenFinder) (*intent, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no operations")
+	}
+	switch ops[0].Type {
+	case icon.TransferOpType:
+		return parseTransferIntent(ops, findToken)
+	case icon.StakeOpType, icon.UnstakeOpType:
+		return parseStakeIntent(ops)
+	case icon.DelegateOpType:
+		return parseDelegateIntent(ops)
+	case icon.ClaimOpType:
+		return parseClaimIntent(ops)
+	case icon.CallOpType:
+		return parseCallIntent(ops)
+	case icon.DeployOpType:
+		return parseDeployIntent(ops)
+	case icon.FSDepositOpType:
+		return parseDepositIntent(ops)
+	case icon.FSWithdrawOpType:
+		return parseWithdrawIntent(ops)
+	default:
+		return nil, fmt.Errorf("unsupported operation type %s", ops[0].Type)
+	}
+}
+
+func parseTransferIntent(ops []*types.Operation, findToken tokenFinder) (*intent, error) {
+	m, err := parser.MatchOperations(transferDescriptions, ops)
+	if err != nil {
+		return nil, err
+	}
+	f, _ := m[0].First()
+	t, amount := m[1].First()
+	currency := t.Amount.Currency
+	if types.Hash(f.Amount.Currency) != types.Hash(currency) {
+		return nil, fmt.Errorf("currency mismatch")
+	}
+	var meta transferMetadata
+	for _, op := range []*types.Operation{f, t} {
+		if err := icon.UnmarshalJSONMap(op.Metadata, &meta); err != nil {
+			return nil, err
+		}
+	}
+	if types.Hash(currency) == types.Hash(icon.ICXCurrency) {
+		it := &intent{
+			OpType: icon.TransferOpType,
+			From:   f.Account.Address,
+			To:     t.Account.Address,
+			Value:  amount,
+		}
+		if len(meta.Memo) > 0 {
+			if !utf8.ValidString(meta.Memo) {
+				return nil, fmt.Errorf("memo is not valid UTF-8")
+			}
+			it.DataType = types.String(icon.MessageDataType)
+			it.Data = icon.EncodeMemo(meta.Memo)
+		}
+		return it, nil
+	}
+
+	address, _ := currency.Metadata[icon.ContractAddressKey].(string)
+	token, err := findToken(address)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || types.Hash(token.Currency()) != types.Hash(currency) {
+		return nil, fmt.Errorf("unsupported currency %s", currency.Symbol)
+	}
+	if len(meta.Memo) > 0 {
+		return nil, fmt.Errorf("memo is not supported for %s transfers", currency.Symbol)
+	}
+	return &intent{
+		OpType:   icon.TransferOpType,
+		From:     f.Account.Address,
+		To:       token.Address,
+		DataType: types.String(icon.CallDataType),
+		Data: &icon.CallData{
+			Method: icon.TokenTransferMethod,
+			Params: map[string]interface{}{
+				"_to":    t.Account.Address,
+				"_value": (&common.HexInt{Int: *amount}).String(),
+			},
+		},
+		Accounts: []string{t.Account.Address},
+	}, nil
+}
+
+func parseStakeIntent(ops []*types.Operation) (*intent, error) {
+	d := stakeDescriptions
+	if ops[0].Type == icon.UnstakeOpType {
+		d = unstakeDescriptions
+	}
+	m, err := parser.MatchOperations(d, ops)
+	if err != nil {
+		return nil, err
+	}
+	op, _ := m[0].First()
+	stake := new(big.Int)
+	if op.Type == icon.StakeOpType {
+		var meta stakeMetadata
+		if err := icon.UnmarshalJSONMap(op.Metadata, &meta); err != nil {
+			return nil, err
+		}
+		if _, ok := stake.SetString(meta.Stake, 10); !ok || stake.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid stake %s", meta.Stake)
+		}
+	}
+	return &intent{
+		OpType:   op.Type,
+		From:     op.Account.Address,
+		To:       icon.SystemScoreAddress,
+		DataType: types.String(icon.CallDataType),
+		Data: &icon.CallData{
+			Method: icon.SetStakeMethod,
+			Params: map[string]interface{}{
+				"value": (&common.HexInt{Int: *stake}).String(),
+			},
+		},
+	}, nil
+}
+
+func parseDelegateIntent(ops []*types.Operation) (*intent, error) {
+	m, err := parser.MatchOperations(delegateDescriptions, ops)
+	if err != nil {
+		return nil, err
+	}
+	op, _ := m[0].First()
+	var meta delegateMetadata
+	if err := icon.UnmarshalJSONMap(op.Metadata, &meta); err != nil {
+		return nil, err
+	}
+	delegations := make([]*icon.Delegation, len(meta.Delegations))
+	prs := make([]string, len(meta.Delegations))
+	for i, d := range meta.Delegations {
+		value, ok := new(big.Int).SetString(d.Value, 10)
+		if !ok || value.Sign() < 0 {
+			return nil, fmt.Errorf("invalid delegation value %s", d.Value)
+		}
+		delegations[i] = &icon.Delegation{
+			Address: d.Address,
+			Value:   (&common.HexInt{Int: *value}).String(),
+		}
+		prs[i] = d.Address
+	}
+	return &intent{
+		OpType:   icon.DelegateOpType,
+		From:     op.Account.Address,
+		To:       icon.SystemScoreAddress,
+		DataType: types.String(icon.CallDataType),
+		Data: &icon.CallData{
+			Method: icon.SetDelegationMethod,
+			Params: map[string]interface{}{
+				"delegations": delegations,
+			},
+		},
+		Accounts: prs,
+	}, nil
+}
+
+func parseClaimIntent(ops []*types.Operation) (*intent, error) {
+	m, err := parser.MatchOperations(claimDescriptions, ops)
+	if err != nil {
+		return nil, err
+	}
+	op, _ := m[0].First()
+	return &intent{
+		OpType:   icon.ClaimOpType,
+		From:     op.Account.Address,
+		To:       icon.SystemScoreAddress,
+		DataType: types.String(icon.CallDataType),
+		Data: &icon.CallData{
+			Method: icon.ClaimIScoreMethod,
+		},
+	}, nil
+}
+
+func parseCallIntent(ops []*types.Operation) (*intent, error) {
+	m, err := parser.MatchOperations(callDescriptions, ops)
+	if err != nil {
+		return nil, err
+	}
+	op, amount := m[0].First()
+	var meta callMetadata
+	if err := icon.UnmarshalJSONMap(op.Metadata, &meta); err != nil {
+		return nil, err
+	}
+	if meta.Method == "" {
+		return nil, fmt.Errorf("missing method")
+	}
+	var value *big.Int
+	if amount != nil {
+		if types.Hash(op.Amount.Currency) != types.Hash(icon.ICXCurrency) {
+			return nil, fmt.Errorf("unsupported currency %s", op.Amount.Currency.Symbol)
+		}
+		if amount.Sign() > 0 {
+			return nil, fmt.Errorf("call amount must not be positive")
+		}
+		value = new(big.Int).Neg(amount)
+	}
+	return &intent{
+		OpType:   icon.CallOpType,
+		From:     op.Account.Address,
+		To:       meta.To,
+		Value:    value,
+		DataType: types.String(icon.CallDataType),
+		Data: &icon.CallData{
+			Method: meta.Method,
+			Params: meta.Params,
+		},
+	}, nil
+}
+
+func parseDeployIntent(ops []*types.Operation) (*intent, error) {
+	m, err := parser.MatchOperations(deployDescriptions, ops)
+	if err != nil {
+		return nil, err
+	}
+	op, _ := m[0].First()
+	var meta deployMetadata
+	if err := icon.UnmarshalJSONMap(op.Metadata, &meta); err != nil {
+		return nil, err
+	}
+	if meta.ContentType != icon.ContentTypeZip && meta.ContentType != icon.ContentTypeJava {
+		return nil, fmt.Errorf("unsupported content type %s", meta.ContentType)
+	}
+	content, err := hex.DecodeString(strings.TrimPrefix(meta.Content, "0x"))
+	if err != nil || len(content) == 0 || !strings.HasPrefix(meta.Content, "0x") {
+		return nil, fmt.Errorf("invalid content")
+	}
+	return &intent{
+		OpType:   icon.DeployOpType,
+		From:     op.Account.Address,
+		To:       icon.SystemScoreAddress,
+		DataType: types.String(icon.DeployDataType),
+		Data: &icon.DeployData{
+			ContentType: meta.ContentType,
+			Content:     meta.Content,
+			Params:      meta.Params,
+		},
+	}, nil
+}
+
+func parseDepositIntent(ops []*types.Operation) (*intent, error) {
+	m, err := parser.MatchOperations(depositDescriptions, ops)
+	if err != nil {
+		return nil, err
+	}
+	f, _ := m[0].First()
+	t, amount := m[1].First()
+	if !icon.IsContract(t.Account.Address) {
+		return nil, fmt.Errorf("%s is not a contract", t.Account.Address)
+	}
+	return &intent{
+		OpType:   icon.FSDepositOpType,
+		From:     f.Account.Address,
+		To:       t.Account.Address,
+		Value:    amount,
+		DataType: types.String(icon.DepositDataType),
+		Data: &icon.DepositData{
+			Action: icon.DepositAddAction,
+		},
+	}, nil
+}
+
+func parseWithdrawIntent(ops []*types.Operation) (*intent, error) {
+	m, err := parser.MatchOperations(withdrawDescriptions, ops)
+	if err != nil {
+		return nil, err
+	}
+	op, _ := m[0].First()
+	var meta withdrawMetadata
+	if err := icon.UnmarshalJSONMap(op.Metadata, &meta); err != nil {
+		return nil, err
+	}
+	if !icon.IsContract(meta.To) {
+		return nil, fmt.Errorf("%s is not a contract", meta.To)
+	}
+	data := &icon.DepositData{
+		Action: icon.DepositWithdrawAction,
+	}
+	switch {
+	case len(meta.ID) > 0 && len(meta.Amount) > 0:
+		return nil, fmt.Errorf("either id or amount must be given")
+	case len(meta.ID) > 0:
+		if _, err := hex.DecodeString(strings.TrimPrefix(meta.ID, "0x")); err != nil || !strings.HasPrefix(meta.ID, "0x") {
+			return nil, fmt.Errorf("invalid deposit id %s", meta.ID)
+		}
+		data.ID = meta.ID
+	case len(meta.Amount) > 0:
+		amount, ok := new(big.Int).SetString(meta.Amount, 10)
+		if !ok || amount.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid amount %s", meta.Amount)
+		}
+		data.Amount = (&common.HexInt{Int: *amount}).String()
+	}
+	return &intent{
+		OpType:   icon.FSWithdrawOpType,
+		From:     op.Account.Address,
+		To:       meta.To,
+		DataType: types.String(icon.DepositDataType),
+		Data:     data,
+	}, nil
+}
+
+// options returns the options of /construction/preprocess
+// which carry the intent to /construction/metadata.
+func (it *intent) options() (*options, error) {
+	o := &options{
+		From:     it.From,
+		OpType:   it.OpType,
+		To:       it.To,
+		DataType: it.DataType,
+	}
+	if it.Value != nil {
+		o.Value = &common.HexInt{Int: *it.Value}
+	}
+	if it.Data != nil {
+		bs, err := json.Marshal(it.Data)
+		if err != nil {
+			return nil, err
+		}
+		o.Data = bs
+	}
+	return o, nil
+}
+
+// intent returns the intent carried by the options.
+func (o *options) intent() *intent {
+	it := &intent{
+		OpType:   o.OpType,
+		From:     o.From,
+		To:       o.To,
+		DataType: o.DataType,
+	}
+	if o.Value != nil {
+		it.Value = &o.Value.Int
+	}
+	if o.Data != nil {
+		it.Data = o.Data
+	}
+	return it
+}
+
+// transaction builds the unsigned ICON transaction of the intent.
+func (it *intent) transaction(stepLimit *common.HexInt, nid *common.HexInt) (*icon.Transaction, error) {
+	for _, a := range []string{it.From, it.To} {
+		if err := icon.CheckAddress(a); err != nil {
+			return nil, fmt.Errorf("%s is not a valid address", a)
+		}
+	}
+	tx := &icon.Transaction{
+		Version:   common.HexUint16{Value: 3},
+		From:      *common.MustNewAddressFromString(it.From),
+		To:        *common.MustNewAddressFromString(it.To),
+		StepLimit: *stepLimit,
+		NID:       nid,
+		DataType:  it.DataType,
+	}
+	if it.Value != nil {
+		tx.Value = &common.HexInt{Int: *it.Value}
+	}
+	if it.Data != nil {
+		bs, err := json.Marshal(it.Data)
+		if err != nil {
+			return nil, err
+		}
+		tx.Data = bs
+	}
+	return tx, nil
+}
+
+// parseOperations returns the operations of the transaction,
+// which are the operations of the transaction in a block.
+func parseOperations(tx *icon.Transaction, findToken tokenFinder) ([]*types.Operation, error) {
+	parse := icon.ParseOperationsV3
+	if tx.IsV2() {
+		parse = icon.ParseOperationsV2
+	}
+	parsed, err := parse(*tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The fee of a v3 transaction is known only from its result,
+	// as its step limit is no ICX amount
+	ops := make([]*types.Operation, 0, len(parsed))
+	for _, op := range parsed {
+		if op.Type != icon.FeeOpType || tx.IsV2() {
+			ops = append(ops, op)
+		}
+	}
+
+	if tx.GetDataType() == icon.CallDataType {
+		token, err := findToken(tx.ToAddr())
+		if err != nil {
+			return nil, err
+		}
+		if token != nil {
+			lastOpIndex := ops[len(ops)-1].OperationIdentifier.Index
+			ops = append(ops, icon.GetTokenTransferOperations(*tx, token.Currency(), lastOpIndex)...)
+		}
+	}
+
+	// Operations of transactions not yet in a block have no status
+	for _, op := range ops {
+		op.Status = nil
+	}
+	return ops, nil
+}
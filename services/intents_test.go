@@ -0,0 +1,184 @@
+// Copyright 2020 ICON Foundation, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/coinbase/rosetta-sdk-go/parser"
+	"github.com/coinbase/rosetta-sdk-go/types"
+	"github.com/icon-project/rosetta-icon/configuration"
+	"github.com/icon-project/rosetta-icon/icon"
+)
+
+const (
+	testFrom     = "hxe7af5fcfd8dfc67530a01a0e403882687528dfcb"
+	testTo       = "hx3c7955f918f07df3b30c45b20f829eb8b4c8f6ff"
+	testPRep     = "hx9eec61296a7010c867ce24c20e69588e2832bc52"
+	testContract = "cx8ac3e29aed2fec5cfef6cb6f8d32f3c1cf7de9c5"
+	testToken    = "cx2609b924e33ef00b648a409245c7ea394c467824"
+)
+
+var testTokenCurrency = &types.Currency{
+	Symbol:   "sICX",
+	Decimals: 18,
+	Metadata: map[string]interface{}{
+		icon.ContractAddressKey: testToken,
+	},
+}
+
+func testOperation(opType string, address string, value string, meta map[string]interface{}) *types.Operation {
+	op := &types.Operation{
+		Type: opType,
+		Account: &types.AccountIdentifier{
+			Address: address,
+		},
+		Metadata: meta,
+	}
+	if len(value) > 0 {
+		op.Amount = &types.Amount{
+			Value:    value,
+			Currency: icon.ICXCurrency,
+		}
+	}
+	return op
+}
+
+func testIntents() map[string][]*types.Operation {
+	tokenFrom := testOperation(icon.TransferOpType, testFrom, "-5", nil)
+	tokenFrom.Amount.Currency = testTokenCurrency
+	tokenTo := testOperation(icon.TransferOpType, testTo, "5", nil)
+	tokenTo.Amount.Currency = testTokenCurrency
+	depositTo := testOperation(icon.FSDepositOpType, testContract, "100", nil)
+	depositTo.Account.SubAccount = &types.SubAccountIdentifier{
+		Address: icon.DepositSubAccount,
+	}
+
+	return map[string][]*types.Operation{
+		"transfer": {
+			testOperation(icon.TransferOpType, testFrom, "-10", nil),
+			testOperation(icon.TransferOpType, testTo, "10", nil),
+		},
+		"memo": {
+			testOperation(icon.TransferOpType, testFrom, "-10", map[string]interface{}{
+				"memo": "deposit 1234",
+			}),
+			testOperation(icon.TransferOpType, testTo, "10", nil),
+		},
+		"token": {tokenFrom, tokenTo},
+		"stake": {
+			testOperation(icon.StakeOpType, testFrom, "", map[string]interface{}{
+				"stake": "1000",
+			}),
+		},
+		"unstake": {
+			testOperation(icon.UnstakeOpType, testFrom, "", nil),
+		},
+		"delegate": {
+			testOperation(icon.DelegateOpType, testFrom, "", map[string]interface{}{
+				"delegations": []interface{}{
+					map[string]interface{}{
+						"address": testPRep,
+						"value":   "1000",
+					},
+				},
+			}),
+		},
+		"claim": {
+			testOperation(icon.ClaimOpType, testFrom, "", nil),
+		},
+		"call": {
+			testOperation(icon.CallOpType, testFrom, "-3", map[string]interface{}{
+				"to":     testContract,
+				"method": "vote",
+				"params": map[string]interface{}{
+					"id": "0x1",
+				},
+			}),
+		},
+		"deploy": {
+			testOperation(icon.DeployOpType, testFrom, "", map[string]interface{}{
+				"content_type": icon.ContentTypeJava,
+				"content":      "0x504b0304",
+				"params": map[string]interface{}{
+					"name": "test",
+				},
+			}),
+		},
+		"fs_deposit": {
+			testOperation(icon.FSDepositOpType, testFrom, "-100", nil),
+			depositTo,
+		},
+		"fs_withdraw": {
+			testOperation(icon.FSWithdrawOpType, testFrom, "", map[string]interface{}{
+				"to":     testContract,
+				"amount": "50",
+			}),
+		},
+	}
+}
+
+// TestIntentRoundTrip checks that /construction/parse returns the
+// operations of every intent given to /construction/payloads, as
+// rosetta-cli expects.
+func TestIntentRoundTrip(t *testing.T) {
+	cfg := &configuration.Configuration{
+		Mode: configuration.Offline,
+		Network: &types.NetworkIdentifier{
+			Blockchain: icon.Blockchain,
+			Network:    icon.LisbonNetwork,
+		},
+		Tokens: []*icon.Token{
+			{
+				Symbol:   "sICX",
+				Address:  testToken,
+				Decimals: 18,
+			},
+		},
+	}
+	s := NewConstructionAPIService(cfg, nil)
+	ctx := context.Background()
+	p := &parser.Parser{}
+
+	for name, intent := range testIntents() {
+		t.Run(name, func(t *testing.T) {
+			payloads, rErr := s.ConstructionPayloads(ctx, &types.ConstructionPayloadsRequest{
+				NetworkIdentifier: cfg.Network,
+				Operations:        intent,
+				Metadata: map[string]interface{}{
+					"default_step_cost": "0x186a0",
+					"step_limit":        "0x30d40",
+					"timestamp":         "0x5e7d6b1f2e8c0",
+					"nonce":             "0x1",
+				},
+			})
+			if rErr != nil {
+				t.Fatalf("payloads: %s %v", rErr.Message, rErr.Details)
+			}
+			parsed, rErr := s.ConstructionParse(ctx, &types.ConstructionParseRequest{
+				NetworkIdentifier: cfg.Network,
+				Signed:            false,
+				Transaction:       payloads.UnsignedTransaction,
+			})
+			if rErr != nil {
+				t.Fatalf("parse: %s %v", rErr.Message, rErr.Details)
+			}
+			if err := p.ExpectedOperations(intent, parsed.Operations, false, false); err != nil {
+				t.Fatalf("operations do not match: %v", err)
+			}
+		})
+	}
+}
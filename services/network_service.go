@@ -67,6 +67,7 @@ func (s *NetworkAPIService) NetworkOptions(
 			OperationTypes:          icon.OperationTypes,
 			OperationStatuses:       icon.OperationStatuses,
 			HistoricalBalanceLookup: icon.HistoricalBalanceSupported,
+			BalanceExemptions:       icon.BalanceExemptions,
 		},
 	}, nil
 }
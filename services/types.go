@@ -15,6 +15,8 @@
 package services
 
 import (
+	"encoding/json"
+
 	"github.com/coinbase/rosetta-sdk-go/types"
 	"github.com/icon-project/goloop/common"
 	"github.com/icon-project/rosetta-icon/icon"
@@ -36,19 +38,79 @@ type Client interface {
 	GetBalance(
 		account *types.AccountIdentifier,
 		block *types.PartialBlockIdentifier,
+		currencies []*types.Currency,
 	) (*types.AccountBalanceResponse, error)
 
 	GetDefaultStepCost() (*common.HexInt, error)
 
+	GetStepPrice() (*common.HexInt, error)
+
+	EstimateStep(tx icon.Transaction) (*common.HexInt, error)
+
+	GetIScore(address string) (*icon.IScore, error)
+
+	GetToken(address string) (*icon.Token, error)
+
 	SendTransaction(
 		tx icon.Transaction,
 	) error
+
+	SimulateTransaction(
+		tx icon.Transaction,
+	) (*icon.Simulation, error)
+}
+
+// submitOptions are the options of /construction/submit, which
+// are given in the signed transaction as its request has no metadata.
+type submitOptions struct {
+	Simulate bool `json:"simulate,omitempty"`
 }
 
 type options struct {
-	From string `json:"from"`
+	From     string          `json:"from"`
+	OpType   string          `json:"op_type"`
+	To       string          `json:"to"`
+	Value    *common.HexInt  `json:"value,omitempty"`
+	DataType *string         `json:"data_type,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+
+	MaxFee        *common.HexInt `json:"max_fee,omitempty"`
+	FeeMultiplier *float64       `json:"fee_multiplier,omitempty"`
+
+	Timestamp *common.HexInt64 `json:"timestamp,omitempty"`
+	Nonce     *common.HexInt   `json:"nonce,omitempty"`
+
+	SignatureType types.SignatureType `json:"signature_type,omitempty"`
+}
+
+// preprocessMetadata is the metadata of /construction/preprocess
+// fixing the timestamp and the nonce of the transaction, and
+// the signature type of its signing payload.
+type preprocessMetadata struct {
+	Timestamp *common.HexInt64 `json:"timestamp,omitempty"`
+	Nonce     *common.HexInt   `json:"nonce,omitempty"`
+
+	SignatureType types.SignatureType `json:"signature_type,omitempty"`
+}
+
+// unsignedTransaction is the unsigned transaction returned by
+// /construction/payloads with the deadline, in microseconds,
+// until which it can be submitted.
+type unsignedTransaction struct {
+	icon.Transaction
+	Deadline *common.HexInt64 `json:"deadline,omitempty"`
 }
 
 type metadata struct {
 	DefaultStepCost *common.HexInt `json:"default_step_cost"`
+	StepLimit       *common.HexInt `json:"step_limit,omitempty"`
+	StepPrice       *common.HexInt `json:"step_price,omitempty"`
+
+	Timestamp *common.HexInt64 `json:"timestamp,omitempty"`
+	Nonce     *common.HexInt   `json:"nonce,omitempty"`
+
+	IScore       *common.HexInt `json:"iscore,omitempty"`
+	EstimatedICX *common.HexInt `json:"estimated_icx,omitempty"`
+
+	SignatureType types.SignatureType `json:"signature_type,omitempty"`
 }